@@ -4,6 +4,9 @@ import (
 	"context"
 	"crypto-orderbook/internal/config"
 	"crypto-orderbook/internal/database"
+	"crypto-orderbook/internal/engine"
+	"crypto-orderbook/internal/fees"
+	"crypto-orderbook/internal/funding"
 	"crypto-orderbook/internal/handlers"
 	"crypto-orderbook/internal/middleware"
 	"crypto-orderbook/internal/repository"
@@ -18,6 +21,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 	ws "github.com/gofiber/websocket/v2"
 )
 
@@ -43,15 +47,54 @@ func main() {
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db.Pool)
 	orderRepo := repository.NewOrderRepository(db.Pool)
+	tradeRepo := repository.NewTradeRepository(db.Pool)
+	marketRepo := repository.NewMarketRepository(db.Pool)
+	orderEventRepo := repository.NewOrderEventRepository(db.Pool)
+	balanceRepo := repository.NewBalanceRepository(db.Pool)
+	depositRepo := repository.NewDepositRepository(db.Pool)
+	withdrawalRepo := repository.NewWithdrawalRepository(db.Pool)
 
 	// Initialize WebSocket hub
-	hub := websocket.NewHub()
+	hub := websocket.NewHub(cfg.JWT.Secret)
 	go hub.Run()
 
+	// Initialize matching engine from the persisted book
+	matchingEngine := engine.NewEngine(db.Pool, hub)
+	if err := matchingEngine.Load(context.Background()); err != nil {
+		log.Fatal("Failed to load order book:", err)
+	}
+
+	// Open the WebSocket channels of every market
+	hub.SetSymbols(matchingEngine.Symbols())
+
+	// Cancel the orders of cancel-on-disconnect sessions once they end
+	hub.OnSessionClosed(func(userID int64, sessionID string) {
+		matchingEngine.CancelSession(context.Background(), userID, sessionID)
+	})
+
+	// Initialize simulated deposits and withdrawals
+	fundingService := funding.NewService(db.Pool, hub, cfg.Funding.DepositConfirmations)
+
+	// Initialize volume-based fee tiers
+	feeService := fees.NewService(db.Pool, cfg.Fees.VolumeAsset)
+
+	// Expire good-til-date orders, confirm deposits and recompute fee tiers
+	// in the background
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go matchingEngine.RunExpiry(workerCtx, time.Second)
+	go fundingService.RunConfirmations(workerCtx, cfg.Funding.ConfirmationInterval)
+	go feeService.RunTiers(workerCtx, cfg.Fees.TierInterval)
+
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(userRepo, cfg)
-	orderHandler := handlers.NewOrderHandler(orderRepo, hub)
-	wsHandler := handlers.NewWebSocketHandler(hub)
+	orderHandler := handlers.NewOrderHandler(orderRepo, orderEventRepo, marketRepo, matchingEngine)
+	tradeHandler := handlers.NewTradeHandler(tradeRepo, marketRepo)
+	marketHandler := handlers.NewMarketHandler(marketRepo)
+	accountHandler := handlers.NewAccountHandler(userRepo, feeService)
+	balanceHandler := handlers.NewBalanceHandler(balanceRepo)
+	fundingHandler := handlers.NewFundingHandler(fundingService, depositRepo, withdrawalRepo, marketRepo)
+	wsHandler := handlers.NewWebSocketHandler(hub, cfg)
 
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
@@ -59,11 +102,12 @@ func main() {
 	})
 
 	// Middleware
+	app.Use(recover.New())
 	app.Use(logger.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "http://localhost:5173, http://localhost:3000",
 		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
-		AllowMethods:     "GET, POST, PUT, DELETE",
+		AllowMethods:     "GET, POST, PUT, PATCH, DELETE",
 		AllowCredentials: true,
 	}))
 
@@ -81,11 +125,51 @@ func main() {
 	auth.Post("/register", authHandler.Register)
 	auth.Post("/login", authHandler.Login)
 
+	// Protected account routes
+	account := api.Group("/account", middleware.AuthMiddleware(cfg))
+	account.Get("/", accountHandler.GetAccount)
+	account.Patch("/", accountHandler.UpdateAccount)
+	account.Get("/fees", accountHandler.GetFees)
+
+	// Protected balance routes
+	balances := api.Group("/balances", middleware.AuthMiddleware(cfg))
+	balances.Get("/", balanceHandler.GetBalances)
+	balances.Get("/ledger", balanceHandler.GetLedger)
+
+	// Protected funding routes
+	deposits := api.Group("/deposits", middleware.AuthMiddleware(cfg))
+	deposits.Get("/", fundingHandler.GetMyDeposits)
+	deposits.Post("/", fundingHandler.CreateDeposit)
+
+	withdrawals := api.Group("/withdrawals", middleware.AuthMiddleware(cfg))
+	withdrawals.Get("/", fundingHandler.GetMyWithdrawals)
+	withdrawals.Post("/", fundingHandler.CreateWithdrawal)
+
+	// Admin routes
+	admin := api.Group("/admin", middleware.AuthMiddleware(cfg), middleware.AdminMiddleware(cfg))
+	admin.Get("/withdrawals", fundingHandler.GetWithdrawals)
+	admin.Post("/withdrawals/:id/approve", fundingHandler.ApproveWithdrawal)
+	admin.Post("/withdrawals/:id/reject", fundingHandler.RejectWithdrawal)
+	admin.Post("/withdrawals/:id/complete", fundingHandler.CompleteWithdrawal)
+
+	// Protected market routes
+	markets := api.Group("/markets", middleware.AuthMiddleware(cfg))
+	markets.Get("/", marketHandler.GetMarkets)
+
 	// Protected order routes
 	orders := api.Group("/orders", middleware.AuthMiddleware(cfg))
 	orders.Get("/", orderHandler.GetOrderBook)
 	orders.Post("/", orderHandler.CreateOrder)
+	orders.Post("/groups", orderHandler.CreateOrderGroup)
 	orders.Get("/my", orderHandler.GetMyOrders)
+	orders.Patch("/:id", orderHandler.AmendOrder)
+	orders.Get("/:id/events", orderHandler.GetOrderEvents)
+	orders.Delete("/", orderHandler.CancelAllOrders)
+	orders.Delete("/:id", orderHandler.CancelOrder)
+
+	// Protected trade routes
+	trades := api.Group("/trades", middleware.AuthMiddleware(cfg))
+	trades.Get("/", tradeHandler.GetTrades)
 
 	// WebSocket route
 	app.Get("/ws", wsHandler.UpgradeMiddleware(), ws.New(wsHandler.HandleWebSocket))
@@ -107,6 +191,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopWorkers()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
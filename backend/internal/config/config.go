@@ -3,7 +3,10 @@ package config
 import (
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +15,8 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	JWT      JWTConfig
+	Funding  FundingConfig
+	Fees     FeeConfig
 }
 
 type ServerConfig struct {
@@ -33,6 +38,17 @@ type JWTConfig struct {
 	ExpireHours int
 }
 
+type FundingConfig struct {
+	DepositConfirmations int           // Simulated confirmations before a deposit is credited, 0 for straight away
+	ConfirmationInterval time.Duration // Time between two simulated confirmations
+	AdminUserIDs         []int64       // Users who may approve, reject and complete withdrawals
+}
+
+type FeeConfig struct {
+	VolumeAsset  string        // Asset 30-day volume is valued in for fee tiers
+	TierInterval time.Duration // Time between two recomputations of the fee tiers
+}
+
 func Load() (*Config, error) {
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
@@ -40,6 +56,28 @@ func Load() (*Config, error) {
 	}
 
 	jwtExpire, _ := strconv.Atoi(getEnv("JWT_EXPIRE_HOURS", "24"))
+	confirmations, _ := strconv.Atoi(getEnv("DEPOSIT_CONFIRMATIONS", "3"))
+	confirmationSeconds, _ := strconv.Atoi(getEnv("DEPOSIT_CONFIRMATION_SECONDS", "10"))
+	if confirmationSeconds <= 0 {
+		confirmationSeconds = 10
+	}
+
+	tierMinutes, _ := strconv.Atoi(getEnv("FEE_TIER_INTERVAL_MINUTES", "60"))
+	if tierMinutes <= 0 {
+		tierMinutes = 60
+	}
+
+	var adminUserIDs []int64
+	for _, field := range strings.Split(getEnv("ADMIN_USER_IDS", ""), ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid user id in ADMIN_USER_IDS: %q", field)
+		}
+		adminUserIDs = append(adminUserIDs, id)
+	}
 
 	config := &Config{
 		Server: ServerConfig{
@@ -58,6 +96,15 @@ func Load() (*Config, error) {
 			Secret:      getEnv("JWT_SECRET", "your-secret-key"),
 			ExpireHours: jwtExpire,
 		},
+		Funding: FundingConfig{
+			DepositConfirmations: max(confirmations, 0),
+			ConfirmationInterval: time.Duration(confirmationSeconds) * time.Second,
+			AdminUserIDs:         adminUserIDs,
+		},
+		Fees: FeeConfig{
+			VolumeAsset:  strings.ToUpper(getEnv("FEE_VOLUME_ASSET", "USDT")),
+			TierInterval: time.Duration(tierMinutes) * time.Minute,
+		},
 	}
 
 	return config, nil
@@ -70,6 +117,12 @@ func (c *DatabaseConfig) ConnectionString() string {
 	)
 }
 
+// IsAdmin reports whether the user may run admin actions. Admins are
+// configured by user id, as nothing in the app verifies emails.
+func (c *FundingConfig) IsAdmin(userID int64) bool {
+	return slices.Contains(c.AdminUserIDs, userID)
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -0,0 +1,282 @@
+// Package decimal implements the fixed-point numbers used for prices,
+// quantities and balances. Values carry exactly Scale fractional digits,
+// matching the DECIMAL(18,8) columns they are stored in, so arithmetic never
+// goes through binary floating point.
+package decimal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Scale is the number of fractional digits every Decimal carries
+const Scale = 8
+
+var (
+	scaleFactor = big.NewInt(100_000_000)
+	bigTen      = big.NewInt(10)
+)
+
+// Decimal is an exact decimal number with Scale fractional digits. The zero
+// value is 0 and ready to use. Decimals are immutable; arithmetic returns new
+// values.
+type Decimal struct {
+	units *big.Int // value * 10^Scale
+}
+
+// Zero is the Decimal 0
+var Zero = Decimal{}
+
+// NewFromInt returns the Decimal for a whole number
+func NewFromInt(i int64) Decimal {
+	return Decimal{units: new(big.Int).Mul(big.NewInt(i), scaleFactor)}
+}
+
+// Parse reads a plain decimal string such as "42", "-0.5" or "100.12345678".
+// More than Scale fractional digits is an error rather than being rounded.
+func Parse(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Zero, fmt.Errorf("invalid decimal: empty string")
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return Zero, fmt.Errorf("invalid decimal: %q", s)
+	}
+	if len(fracPart) > Scale {
+		return Zero, fmt.Errorf("invalid decimal: %q has more than %d decimal places", s, Scale)
+	}
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return Zero, fmt.Errorf("invalid decimal: %q", s)
+		}
+	}
+
+	digits := intPart + fracPart + strings.Repeat("0", Scale-len(fracPart))
+	units, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Zero, fmt.Errorf("invalid decimal: %q", s)
+	}
+	if negative {
+		units.Neg(units)
+	}
+
+	return Decimal{units: units}, nil
+}
+
+// MustParse is like Parse but panics on invalid input. It is meant for
+// constants.
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d Decimal) int() *big.Int {
+	if d.units == nil {
+		return new(big.Int)
+	}
+	return d.units
+}
+
+// Add returns d + o
+func (d Decimal) Add(o Decimal) Decimal {
+	return Decimal{units: new(big.Int).Add(d.int(), o.int())}
+}
+
+// Sub returns d - o
+func (d Decimal) Sub(o Decimal) Decimal {
+	return Decimal{units: new(big.Int).Sub(d.int(), o.int())}
+}
+
+// Neg returns -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{units: new(big.Int).Neg(d.int())}
+}
+
+// Abs returns |d|
+func (d Decimal) Abs() Decimal {
+	return Decimal{units: new(big.Int).Abs(d.int())}
+}
+
+// Mul returns d * o truncated to Scale fractional digits
+func (d Decimal) Mul(o Decimal) Decimal {
+	units := new(big.Int).Mul(d.int(), o.int())
+	return Decimal{units: units.Quo(units, scaleFactor)}
+}
+
+// Div returns d / o truncated to Scale fractional digits. It panics if o is
+// zero.
+func (d Decimal) Div(o Decimal) Decimal {
+	units := new(big.Int).Mul(d.int(), scaleFactor)
+	return Decimal{units: units.Quo(units, o.int())}
+}
+
+// IsMultipleOf reports whether d is a whole multiple of step. A zero step
+// accepts any value.
+func (d Decimal) IsMultipleOf(step Decimal) bool {
+	if step.IsZero() {
+		return true
+	}
+	return new(big.Int).Rem(d.int(), step.int()).Sign() == 0
+}
+
+// Cmp compares d and o and returns -1, 0 or +1
+func (d Decimal) Cmp(o Decimal) int {
+	return d.int().Cmp(o.int())
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// Equal reports whether d == o
+func (d Decimal) Equal(o Decimal) bool {
+	return d.Cmp(o) == 0
+}
+
+// LessThan reports whether d < o
+func (d Decimal) LessThan(o Decimal) bool {
+	return d.Cmp(o) < 0
+}
+
+// LessThanOrEqual reports whether d <= o
+func (d Decimal) LessThanOrEqual(o Decimal) bool {
+	return d.Cmp(o) <= 0
+}
+
+// GreaterThan reports whether d > o
+func (d Decimal) GreaterThan(o Decimal) bool {
+	return d.Cmp(o) > 0
+}
+
+// GreaterThanOrEqual reports whether d >= o
+func (d Decimal) GreaterThanOrEqual(o Decimal) bool {
+	return d.Cmp(o) >= 0
+}
+
+// IsZero reports whether d is 0
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// IsPositive reports whether d > 0
+func (d Decimal) IsPositive() bool {
+	return d.Sign() > 0
+}
+
+// IsNegative reports whether d < 0
+func (d Decimal) IsNegative() bool {
+	return d.Sign() < 0
+}
+
+// Min returns the smaller of a and b
+func Min(a, b Decimal) Decimal {
+	if a.LessThan(b) {
+		return a
+	}
+	return b
+}
+
+// Max returns the larger of a and b
+func Max(a, b Decimal) Decimal {
+	if a.GreaterThan(b) {
+		return a
+	}
+	return b
+}
+
+// String formats d without trailing fractional zeros, e.g. "100.5"
+func (d Decimal) String() string {
+	units := d.int()
+	digits := new(big.Int).Abs(units).String()
+	if len(digits) <= Scale {
+		digits = strings.Repeat("0", Scale-len(digits)+1) + digits
+	}
+
+	intPart := digits[:len(digits)-Scale]
+	fracPart := strings.TrimRight(digits[len(digits)-Scale:], "0")
+
+	s := intPart
+	if fracPart != "" {
+		s += "." + fracPart
+	}
+	if units.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// MarshalJSON encodes d as a JSON string so clients never round it through
+// a float
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts either a JSON string or a JSON number. Numbers are
+// parsed from their literal text, not through float64.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = Zero
+		return nil
+	}
+
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// ScanNumeric implements pgtype.NumericScanner. NULL scans as zero.
+func (d *Decimal) ScanNumeric(n pgtype.Numeric) error {
+	if !n.Valid {
+		*d = Zero
+		return nil
+	}
+	if n.NaN || n.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("cannot scan %v into decimal", n)
+	}
+
+	units := new(big.Int).Set(n.Int)
+	exp := int64(n.Exp) + Scale
+	if exp >= 0 {
+		units.Mul(units, new(big.Int).Exp(bigTen, big.NewInt(exp), nil))
+	} else {
+		units.Quo(units, new(big.Int).Exp(bigTen, big.NewInt(-exp), nil))
+	}
+
+	*d = Decimal{units: units}
+	return nil
+}
+
+// NumericValue implements pgtype.NumericValuer
+func (d Decimal) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: new(big.Int).Set(d.int()), Exp: -Scale, Valid: true}, nil
+}
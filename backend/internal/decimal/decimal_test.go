@@ -0,0 +1,214 @@
+package decimal
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "42", want: "42"},
+		{in: "-0.5", want: "-0.5"},
+		{in: "+1.25", want: "1.25"},
+		{in: " 7 ", want: "7"},
+		{in: ".5", want: "0.5"},
+		{in: "5.", want: "5"},
+		{in: "100.12345678", want: "100.12345678"},
+		{in: "0.00000001", want: "0.00000001"},
+		{in: "-0", want: "0"},
+		{in: "123456789012.10000000", want: "123456789012.1"},
+		{in: "0.000000001", wantErr: true},
+		{in: "", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "1e8", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "--1", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %s, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.in, err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Decimal
+		want string
+	}{
+		{"add", MustParse("0.1").Add(MustParse("0.2")), "0.3"},
+		{"sub below zero", MustParse("1").Sub(MustParse("1.5")), "-0.5"},
+		{"neg", MustParse("2.5").Neg(), "-2.5"},
+		{"abs", MustParse("-2.5").Abs(), "2.5"},
+		{"mul", MustParse("1.5").Mul(MustParse("2.25")), "3.375"},
+		{"mul negative", MustParse("-1.5").Mul(MustParse("2")), "-3"},
+		{"mul truncates", MustParse("0.00000001").Mul(MustParse("0.5")), "0"},
+		{"mul truncates towards zero", MustParse("-0.00000003").Mul(MustParse("0.5")), "-0.00000001"},
+		{"div", MustParse("1").Div(MustParse("4")), "0.25"},
+		{"div truncates", MustParse("1").Div(MustParse("3")), "0.33333333"},
+		{"div truncates towards zero", MustParse("-2").Div(MustParse("3")), "-0.66666666"},
+		{"div large", MustParse("99999999999").Div(MustParse("0.00000001")), "9999999999900000000"},
+		{"min", Min(MustParse("-1"), MustParse("2")), "-1"},
+		{"max", Max(MustParse("-1"), MustParse("2")), "2"},
+		{"zero value", Decimal{}.Add(NewFromInt(3)), "3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got.String() != tt.want {
+				t.Errorf("got %s, want %s", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDivByZeroPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Div by zero did not panic")
+		}
+	}()
+	NewFromInt(1).Div(Zero)
+}
+
+func TestComparisons(t *testing.T) {
+	a, b := MustParse("-0.5"), MustParse("0.25")
+
+	if !a.LessThan(b) || a.GreaterThanOrEqual(b) || !b.GreaterThan(a) || a.Equal(b) {
+		t.Errorf("%s and %s compare wrongly", a, b)
+	}
+	if !a.IsNegative() || a.IsPositive() || a.Sign() != -1 {
+		t.Errorf("%s should be negative", a)
+	}
+	if !Zero.IsZero() || !(Decimal{}).Equal(MustParse("0.00")) {
+		t.Error("zero values should be equal and zero")
+	}
+}
+
+func TestIsMultipleOf(t *testing.T) {
+	tests := []struct {
+		d, step string
+		want    bool
+	}{
+		{"1.5", "0.5", true},
+		{"1.55", "0.1", false},
+		{"-0.3", "0.1", true},
+		{"123.45", "0", true},
+		{"0", "0.01", true},
+	}
+
+	for _, tt := range tests {
+		if got := MustParse(tt.d).IsMultipleOf(MustParse(tt.step)); got != tt.want {
+			t.Errorf("%s.IsMultipleOf(%s) = %v, want %v", tt.d, tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		A Decimal `json:"a"`
+		B Decimal `json:"b"`
+		C Decimal `json:"c"`
+	}
+	if err := json.Unmarshal([]byte(`{"a": "1.10", "b": 0.30000000, "c": null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A.String() != "1.1" || v.B.String() != "0.3" || !v.C.IsZero() {
+		t.Errorf("unmarshalled %s, %s, %s", v.A, v.B, v.C)
+	}
+
+	out, err := json.Marshal(v.A)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `"1.1"` {
+		t.Errorf("Marshal = %s, want \"1.1\"", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"a": 0.000000001}`), &v); err == nil {
+		t.Error("too many decimal places should not unmarshal")
+	}
+}
+
+func TestScanNumeric(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       pgtype.Numeric
+		want    string
+		wantErr bool
+	}{
+		{name: "column scale", n: pgtype.Numeric{Int: big.NewInt(12345), Exp: -8, Valid: true}, want: "0.00012345"},
+		{name: "positive exponent", n: pgtype.Numeric{Int: big.NewInt(5), Exp: 3, Valid: true}, want: "5000"},
+		{name: "negative", n: pgtype.Numeric{Int: big.NewInt(-15), Exp: -1, Valid: true}, want: "-1.5"},
+		{name: "extra digits are truncated", n: pgtype.Numeric{Int: big.NewInt(123456789), Exp: -9, Valid: true}, want: "0.12345678"},
+		{name: "null", n: pgtype.Numeric{}, want: "0"},
+		{name: "nan", n: pgtype.Numeric{NaN: true, Valid: true}, wantErr: true},
+		{name: "infinity", n: pgtype.Numeric{InfinityModifier: pgtype.Infinity, Valid: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Decimal
+			err := d.ScanNumeric(tt.n)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ScanNumeric = %s, want an error", d)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.String() != tt.want {
+				t.Errorf("ScanNumeric = %s, want %s", d, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumericRoundTrip(t *testing.T) {
+	for _, s := range []string{"0", "1", "-0.5", "100.12345678", "-99999999999.99999999"} {
+		d := MustParse(s)
+
+		n, err := d.NumericValue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Decimal
+		if err := back.ScanNumeric(n); err != nil {
+			t.Fatal(err)
+		}
+		if !back.Equal(d) {
+			t.Errorf("%s round-tripped to %s", s, back)
+		}
+
+		// NumericValue must not share its digits with d
+		n.Int.SetInt64(7)
+		if d.String() != MustParse(s).String() {
+			t.Errorf("changing the numeric changed %s to %s", s, d)
+		}
+	}
+}
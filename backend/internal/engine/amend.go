@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"context"
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"crypto-orderbook/internal/repository"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Rejection codes of order amendments
+const (
+	CodeAmendPrice  = "AMEND_PRICE_NOT_ALLOWED"
+	CodeAmendAmount = "AMEND_AMOUNT_BELOW_FILLED"
+)
+
+// AmendOrder changes the price, the total amount or both of one of the
+// user's open orders without changing its id; a zero price or amount is left
+// as it is. An order that only shrinks at the same price keeps its place in
+// the queue. Any other change sends it to the back, and a new price may make
+// it trade straight away. The change is recorded in the order's history.
+func (e *Engine) AmendOrder(ctx context.Context, market *models.Market, orderID, userID int64, price, amount decimal.Decimal) (*models.Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.checkMarket(market.Symbol); err != nil {
+		return nil, err
+	}
+	book := e.book(market.Symbol)
+	current, resting := book.orders[orderID]
+	if !resting {
+		current = book.pending[orderID]
+	}
+	if current == nil {
+		return nil, repository.ErrOrderNotOpen
+	}
+	if current.UserID != userID {
+		return nil, repository.ErrOrderNotFound
+	}
+
+	amended := *current
+	if price.IsPositive() {
+		if !amended.HasPrice() {
+			return nil, reject(CodeAmendPrice, "Only limit and stop-limit orders have a price to amend")
+		}
+		amended.Price = price
+	}
+	if amount.IsPositive() {
+		if amount.LessThanOrEqual(amended.FilledAmount) {
+			return nil, reject(CodeAmendAmount, "Amount must be more than the %s already filled", amended.FilledAmount)
+		}
+		amended.Amount = amount
+		amended.RemainingAmount = amount.Sub(amended.FilledAmount)
+	}
+
+	keepPriority := amended.Price.Equal(current.Price) && amended.RemainingAmount.LessThanOrEqual(current.RemainingAmount)
+	if amended.IsIceberg() {
+		if keepPriority {
+			amended.VisibleAmount = decimal.Min(amended.VisibleAmount, amended.RemainingAmount)
+		} else {
+			amended.VisibleAmount = decimal.Min(amended.DisplayAmount, amended.RemainingAmount)
+		}
+	}
+
+	if err := ValidateOrder(market, &amended); err != nil {
+		return nil, err
+	}
+	if !amended.Price.Equal(current.Price) {
+		if err := checkPostOnly(market, book, &amended); err != nil {
+			return nil, err
+		}
+	}
+
+	amendment := models.Amendment{
+		OldPrice:     current.Price,
+		NewPrice:     amended.Price,
+		OldAmount:    current.Amount,
+		NewAmount:    amended.Amount,
+		PriorityKept: keepPriority,
+	}
+
+	var result *Result
+	err := repository.WithTx(ctx, e.db, func(tx pgx.Tx) error {
+		if amended.RemainingAmount.GreaterThan(current.RemainingAmount) {
+			if err := checkReduceOnly(ctx, repository.NewTradeRepository(tx), book, &amended); err != nil {
+				return err
+			}
+		}
+
+		// A resting order pays for its new price and size up front
+		if resting {
+			if err := e.lockFunds(ctx, tx, book, &amended); err != nil {
+				return err
+			}
+		}
+
+		orderRepo := repository.NewOrderRepository(tx)
+		if err := orderRepo.Amend(ctx, &amended, resting && !keepPriority); err != nil {
+			return err
+		}
+		if err := recordAmendment(ctx, tx, &amended, amendment); err != nil {
+			return err
+		}
+
+		// Stop orders and orders keeping their place are changed where they
+		// are; the rest is matched again as if it had just arrived
+		if !resting || keepPriority {
+			if err := orderRepo.UpdateLocked(ctx, &amended); err != nil {
+				return err
+			}
+			*current = amended
+			result = &Result{Order: amended, Hidden: true}
+			return nil
+		}
+
+		book.Remove(orderID)
+		var err error
+		result, err = e.execute(ctx, tx, book, &amended)
+		if err != nil {
+			return err
+		}
+		result.Hidden = true
+		return nil
+	})
+	if err != nil {
+		return nil, e.resync(err)
+	}
+
+	e.broadcast(result)
+	if resting {
+		// Amounts would give away the size of an iceberg order
+		if amended.IsIceberg() {
+			amendment.OldAmount = decimal.Zero
+			amendment.NewAmount = decimal.Zero
+		}
+		e.hub.BroadcastOrderAmended(&result.Order, &amendment)
+	}
+	if len(result.Trades) > 0 {
+		e.runTriggers(ctx, market.Symbol)
+	}
+
+	order := result.Order
+	return &order, nil
+}
+
+func recordAmendment(ctx context.Context, tx pgx.Tx, order *models.Order, amendment models.Amendment) error {
+	data, err := json.Marshal(amendment)
+	if err != nil {
+		return err
+	}
+
+	return repository.NewOrderEventRepository(tx).Create(ctx, &models.OrderEvent{
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		EventType: models.EventAmended,
+		Data:      data,
+	})
+}
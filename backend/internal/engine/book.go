@@ -0,0 +1,340 @@
+package engine
+
+import (
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"sort"
+	"time"
+)
+
+// level is a FIFO queue of resting orders at one price
+type level struct {
+	price  decimal.Decimal
+	orders []*models.Order
+}
+
+// Book keeps the resting orders of one market in memory. Each side is sorted
+// best price first and orders within a level keep arrival order, which gives
+// price-time priority. Stop orders waiting for their trigger are held apart
+// and never match.
+type Book struct {
+	bids    []*level // highest price first
+	asks    []*level // lowest price first
+	orders  map[int64]*models.Order
+	pending map[int64]*models.Order // stop orders by id
+
+	// refreshed lists iceberg orders that showed a new slice during Match, in
+	// the order they went to the back of their level
+	refreshed []*models.Order
+
+	// prevented lists the self-trades Match avoided since the last
+	// TakePrevented
+	prevented []models.SelfTradePrevention
+}
+
+func NewBook() *Book {
+	return &Book{
+		orders:  make(map[int64]*models.Order),
+		pending: make(map[int64]*models.Order),
+	}
+}
+
+// AddStop holds a stop order until its trigger price is reached
+func (b *Book) AddStop(order *models.Order) {
+	b.pending[order.ID] = order
+}
+
+// NextTriggered takes the oldest stop order triggered by lastPrice out of the
+// book, or returns nil if there is none
+func (b *Book) NextTriggered(lastPrice decimal.Decimal) *models.Order {
+	var next *models.Order
+	for _, order := range b.pending {
+		if triggered(order, lastPrice) && (next == nil || order.ID < next.ID) {
+			next = order
+		}
+	}
+	if next != nil {
+		delete(b.pending, next.ID)
+	}
+	return next
+}
+
+// Add rests an order at the back of its price level
+func (b *Book) Add(order *models.Order) {
+	levels := b.side(order.OrderType)
+	i := b.levelIndex(order.OrderType, order.Price)
+	if i < len(*levels) && (*levels)[i].price.Equal(order.Price) {
+		(*levels)[i].orders = append((*levels)[i].orders, order)
+	} else {
+		*levels = append(*levels, nil)
+		copy((*levels)[i+1:], (*levels)[i:])
+		(*levels)[i] = &level{price: order.Price, orders: []*models.Order{order}}
+	}
+	b.orders[order.ID] = order
+}
+
+// Remove takes an order out of the book, reporting whether it was resting or
+// waiting for its trigger
+func (b *Book) Remove(orderID int64) (*models.Order, bool) {
+	if order, ok := b.pending[orderID]; ok {
+		delete(b.pending, orderID)
+		return order, true
+	}
+
+	order, ok := b.orders[orderID]
+	if !ok {
+		return nil, false
+	}
+	delete(b.orders, orderID)
+
+	levels := b.side(order.OrderType)
+	i := b.levelIndex(order.OrderType, order.Price)
+	if i >= len(*levels) || !(*levels)[i].price.Equal(order.Price) {
+		return order, true
+	}
+
+	lvl := (*levels)[i]
+	for j, o := range lvl.orders {
+		if o.ID == orderID {
+			lvl.orders = append(lvl.orders[:j], lvl.orders[j+1:]...)
+			break
+		}
+	}
+	if len(lvl.orders) == 0 {
+		*levels = append((*levels)[:i], (*levels)[i+1:]...)
+	}
+
+	return order, true
+}
+
+// BestPrice returns the best price resting on a side, if any
+func (b *Book) BestPrice(orderType string) (decimal.Decimal, bool) {
+	levels := *b.side(orderType)
+	if len(levels) == 0 {
+		return decimal.Zero, false
+	}
+	return levels[0].price, true
+}
+
+// Fillable reports how much of taker could execute right now at prices no
+// worse than limit, without changing the book, and how much it would still
+// want by then. Its own resting orders are dealt with the way Match would,
+// so a decrement-and-cancel taker wants less for each one it passes.
+func (b *Book) Fillable(taker *models.Order, limit decimal.Decimal) (fillable, wanted decimal.Decimal) {
+	fillable, wanted = decimal.Zero, taker.RemainingAmount
+	for _, lvl := range *b.side(opposite(taker.OrderType)) {
+		if !limit.IsZero() && !crosses(taker.OrderType, limit, lvl.price) {
+			break
+		}
+		for _, maker := range lvl.orders {
+			if selfTrade(taker, maker) {
+				switch {
+				case taker.STPMode == models.STPCancelOldest:
+					continue
+				case taker.STPMode == models.STPDecrementCancel && maker.RemainingAmount.LessThan(wanted.Sub(fillable)):
+					wanted = wanted.Sub(maker.RemainingAmount)
+					continue
+				}
+				return fillable, wanted
+			}
+
+			fillable = fillable.Add(maker.RemainingAmount)
+			if fillable.GreaterThanOrEqual(wanted) {
+				return wanted, wanted
+			}
+		}
+	}
+	return fillable, wanted
+}
+
+// Cost is what taker would pay, in the quote asset, for what it could buy
+// right now at prices no worse than limit, without changing the book. Its
+// own resting orders are dealt with the way Match would.
+func (b *Book) Cost(taker *models.Order, limit decimal.Decimal) decimal.Decimal {
+	cost := decimal.Zero
+	wanted := taker.RemainingAmount
+	for _, lvl := range *b.side(opposite(taker.OrderType)) {
+		if !limit.IsZero() && !crosses(taker.OrderType, limit, lvl.price) {
+			break
+		}
+		for _, maker := range lvl.orders {
+			if selfTrade(taker, maker) {
+				switch {
+				case taker.STPMode == models.STPCancelOldest:
+					continue
+				case taker.STPMode == models.STPDecrementCancel && maker.RemainingAmount.LessThan(wanted):
+					wanted = wanted.Sub(maker.RemainingAmount)
+					continue
+				}
+				return cost
+			}
+
+			qty := decimal.Min(wanted, maker.RemainingAmount)
+			cost = cost.Add(lvl.price.Mul(qty))
+			wanted = wanted.Sub(qty)
+			if wanted.IsZero() {
+				return cost
+			}
+		}
+	}
+	return cost
+}
+
+// Expired returns the resting and stop orders whose good-til-date has passed
+func (b *Book) Expired(now time.Time) []*models.Order {
+	var expired []*models.Order
+	for _, orders := range []map[int64]*models.Order{b.orders, b.pending} {
+		for _, order := range orders {
+			if order.ExpiresAt != nil && !order.ExpiresAt.After(now) {
+				expired = append(expired, order)
+			}
+		}
+	}
+	return expired
+}
+
+// Match executes taker against the opposite side for as long as prices are
+// no worse than limit; a zero limit sweeps any price. Makers are filled
+// oldest first at their own price and fully filled makers leave the book.
+// An iceberg maker only trades its visible slice; once that is used up it
+// shows the next slice from the back of its level. Resting orders of the
+// taker's own user are dealt with by its self-trade prevention mode instead.
+// The taker is never rested here.
+func (b *Book) Match(taker *models.Order, limit decimal.Decimal) (trades []models.Trade, makers []*models.Order) {
+	levels := b.side(opposite(taker.OrderType))
+	touched := make(map[int64]bool)
+
+	for taker.IsOpen() && len(*levels) > 0 {
+		best := (*levels)[0]
+		if !limit.IsZero() && !crosses(taker.OrderType, limit, best.price) {
+			break
+		}
+
+		for taker.IsOpen() && len(best.orders) > 0 {
+			maker := best.orders[0]
+			if selfTrade(taker, maker) {
+				if b.preventSelfTrade(taker, maker, best) && !touched[maker.ID] {
+					touched[maker.ID] = true
+					makers = append(makers, maker)
+				}
+				continue
+			}
+
+			qty := decimal.Min(taker.RemainingAmount, maker.Shown())
+
+			applyFill(taker, best.price, qty)
+			applyFill(maker, best.price, qty)
+			if maker.IsIceberg() {
+				maker.VisibleAmount = maker.VisibleAmount.Sub(qty)
+			}
+			trades = append(trades, models.Trade{
+				Symbol:        taker.Symbol,
+				MakerOrderID:  maker.ID,
+				TakerOrderID:  taker.ID,
+				MakerUserID:   maker.UserID,
+				TakerUserID:   taker.UserID,
+				Price:         best.price,
+				Amount:        qty,
+				AggressorSide: taker.OrderType,
+			})
+			// An iceberg can be hit again after a refresh; report it once
+			if !touched[maker.ID] {
+				touched[maker.ID] = true
+				makers = append(makers, maker)
+			}
+
+			if maker.Status == models.StatusFilled {
+				best.orders = best.orders[1:]
+				delete(b.orders, maker.ID)
+			} else if maker.IsIceberg() && maker.VisibleAmount.IsZero() {
+				maker.VisibleAmount = decimal.Min(maker.DisplayAmount, maker.RemainingAmount)
+				best.orders = append(best.orders[1:], maker)
+				b.refreshed = append(b.refreshed, maker)
+			}
+		}
+
+		if len(best.orders) == 0 {
+			*levels = (*levels)[1:]
+		}
+	}
+
+	return trades, makers
+}
+
+// TakeRefreshed returns the iceberg orders that were sent to the back of
+// their level since the last call, in their new queue order, and forgets
+// them. Orders that have since filled or been cancelled are left out.
+func (b *Book) TakeRefreshed() []*models.Order {
+	var orders []*models.Order
+	seen := make(map[int64]bool)
+	for i := len(b.refreshed) - 1; i >= 0; i-- {
+		order := b.refreshed[i]
+		if seen[order.ID] || !order.IsOpen() {
+			continue
+		}
+		seen[order.ID] = true
+		orders = append(orders, order)
+	}
+	b.refreshed = nil
+
+	// Walked backwards to keep each order's latest refresh; flip it back
+	for i, j := 0, len(orders)-1; i < j; i, j = i+1, j-1 {
+		orders[i], orders[j] = orders[j], orders[i]
+	}
+	return orders
+}
+
+// applyFill books an execution of qty at price against an order
+func applyFill(order *models.Order, price, qty decimal.Decimal) {
+	notional := order.AvgFillPrice.Mul(order.FilledAmount).Add(price.Mul(qty))
+	order.FilledAmount = order.FilledAmount.Add(qty)
+	order.RemainingAmount = order.RemainingAmount.Sub(qty)
+	order.AvgFillPrice = notional.Div(order.FilledAmount)
+
+	if order.RemainingAmount.IsZero() {
+		order.Status = models.StatusFilled
+	} else {
+		order.Status = models.StatusPartiallyFilled
+	}
+}
+
+func (b *Book) side(orderType string) *[]*level {
+	if orderType == "buy" {
+		return &b.bids
+	}
+	return &b.asks
+}
+
+// levelIndex returns where a price level sits (or would be inserted) on a side
+func (b *Book) levelIndex(orderType string, price decimal.Decimal) int {
+	levels := *b.side(orderType)
+	if orderType == "buy" {
+		return sort.Search(len(levels), func(i int) bool { return levels[i].price.LessThanOrEqual(price) })
+	}
+	return sort.Search(len(levels), func(i int) bool { return levels[i].price.GreaterThanOrEqual(price) })
+}
+
+// crosses reports whether a taker on side with the given limit can trade
+// against a maker at makerPrice
+func crosses(side string, limit, makerPrice decimal.Decimal) bool {
+	if side == "buy" {
+		return makerPrice.LessThanOrEqual(limit)
+	}
+	return makerPrice.GreaterThanOrEqual(limit)
+}
+
+// triggered reports whether a stop order fires at lastPrice: a buy once the
+// price has risen to its trigger, a sell once it has fallen to it
+func triggered(order *models.Order, lastPrice decimal.Decimal) bool {
+	if order.OrderType == "buy" {
+		return lastPrice.GreaterThanOrEqual(order.TriggerPrice)
+	}
+	return lastPrice.LessThanOrEqual(order.TriggerPrice)
+}
+
+func opposite(side string) string {
+	if side == "buy" {
+		return "sell"
+	}
+	return "buy"
+}
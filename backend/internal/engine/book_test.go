@@ -0,0 +1,478 @@
+package engine
+
+import (
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"testing"
+)
+
+// limitOrder returns an open GTC limit order
+func limitOrder(id, userID int64, side, price, amount string) *models.Order {
+	return &models.Order{
+		ID:              id,
+		UserID:          userID,
+		Symbol:          "BTC-USDT",
+		OrderType:       side,
+		OrderKind:       models.KindLimit,
+		Price:           decimal.MustParse(price),
+		Amount:          decimal.MustParse(amount),
+		RemainingAmount: decimal.MustParse(amount),
+		Status:          models.StatusActive,
+		TimeInForce:     "GTC",
+	}
+}
+
+// iceberg turns order into an iceberg showing display at a time
+func iceberg(order *models.Order, display string) *models.Order {
+	order.DisplayAmount = decimal.MustParse(display)
+	order.VisibleAmount = decimal.Min(order.DisplayAmount, order.RemainingAmount)
+	return order
+}
+
+// withSTP sets the self-trade prevention mode of order
+func withSTP(order *models.Order, mode string) *models.Order {
+	order.STPMode = mode
+	return order
+}
+
+func newBook(makers ...*models.Order) *Book {
+	book := NewBook()
+	for _, maker := range makers {
+		book.Add(maker)
+	}
+	return book
+}
+
+// queue returns the ids resting on a side in priority order
+func queue(book *Book, side string) []int64 {
+	var ids []int64
+	for _, lvl := range *book.side(side) {
+		for _, order := range lvl.orders {
+			ids = append(ids, order.ID)
+		}
+	}
+	return ids
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type wantTrade struct {
+	maker  int64
+	price  string
+	amount string
+}
+
+func checkTrades(t *testing.T, trades []models.Trade, want []wantTrade) {
+	t.Helper()
+	if len(trades) != len(want) {
+		t.Fatalf("got %d trades, want %d: %+v", len(trades), len(want), trades)
+	}
+	for i, w := range want {
+		got := trades[i]
+		if got.MakerOrderID != w.maker || !got.Price.Equal(decimal.MustParse(w.price)) || !got.Amount.Equal(decimal.MustParse(w.amount)) {
+			t.Errorf("trade %d = maker %d %s @ %s, want maker %d %s @ %s",
+				i, got.MakerOrderID, got.Amount, got.Price, w.maker, w.amount, w.price)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		makers        []*models.Order
+		taker         *models.Order
+		limit         string
+		wantTrades    []wantTrade
+		wantStatus    string
+		wantRemaining string
+		wantQueue     []int64
+	}{
+		{
+			name: "best price first, then arrival order",
+			makers: []*models.Order{
+				limitOrder(1, 1, "sell", "101", "1"),
+				limitOrder(2, 2, "sell", "101", "1"),
+				limitOrder(3, 3, "sell", "100", "1"),
+			},
+			taker:         limitOrder(10, 9, "buy", "101", "2.5"),
+			limit:         "101",
+			wantTrades:    []wantTrade{{3, "100", "1"}, {1, "101", "1"}, {2, "101", "0.5"}},
+			wantStatus:    models.StatusFilled,
+			wantRemaining: "0",
+			wantQueue:     []int64{2},
+		},
+		{
+			name: "stops at the limit price",
+			makers: []*models.Order{
+				limitOrder(1, 1, "sell", "100", "1"),
+				limitOrder(2, 2, "sell", "102", "1"),
+			},
+			taker:         limitOrder(10, 9, "buy", "101", "2"),
+			limit:         "101",
+			wantTrades:    []wantTrade{{1, "100", "1"}},
+			wantStatus:    models.StatusPartiallyFilled,
+			wantRemaining: "1",
+			wantQueue:     []int64{2},
+		},
+		{
+			name: "zero limit sweeps every price",
+			makers: []*models.Order{
+				limitOrder(1, 1, "buy", "100", "1"),
+				limitOrder(2, 2, "buy", "90", "1"),
+			},
+			taker:         limitOrder(10, 9, "sell", "0", "1.5"),
+			limit:         "0",
+			wantTrades:    []wantTrade{{1, "100", "1"}, {2, "90", "0.5"}},
+			wantStatus:    models.StatusFilled,
+			wantRemaining: "0",
+			wantQueue:     []int64{2},
+		},
+		{
+			name:          "nothing crosses",
+			makers:        []*models.Order{limitOrder(1, 1, "sell", "105", "1")},
+			taker:         limitOrder(10, 9, "buy", "100", "1"),
+			limit:         "100",
+			wantStatus:    models.StatusActive,
+			wantRemaining: "1",
+			wantQueue:     []int64{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := newBook(tt.makers...)
+			trades, _ := book.Match(tt.taker, decimal.MustParse(tt.limit))
+
+			checkTrades(t, trades, tt.wantTrades)
+			if tt.taker.Status != tt.wantStatus {
+				t.Errorf("taker status = %s, want %s", tt.taker.Status, tt.wantStatus)
+			}
+			if !tt.taker.RemainingAmount.Equal(decimal.MustParse(tt.wantRemaining)) {
+				t.Errorf("taker remaining = %s, want %s", tt.taker.RemainingAmount, tt.wantRemaining)
+			}
+			if got := queue(book, opposite(tt.taker.OrderType)); !equalIDs(got, tt.wantQueue) {
+				t.Errorf("book = %v, want %v", got, tt.wantQueue)
+			}
+		})
+	}
+}
+
+func TestMatchIcebergRefresh(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      string
+		wantTrades  []wantTrade
+		wantQueue   []int64
+		wantVisible string
+		wantRefresh []int64
+	}{
+		{
+			name:        "slice used up goes behind the level",
+			amount:      "3",
+			wantTrades:  []wantTrade{{1, "100", "2"}, {2, "100", "1"}},
+			wantQueue:   []int64{1},
+			wantVisible: "2",
+			wantRefresh: []int64{1},
+		},
+		{
+			name:        "refreshed slice trades again",
+			amount:      "4",
+			wantTrades:  []wantTrade{{1, "100", "2"}, {2, "100", "1"}, {1, "100", "1"}},
+			wantQueue:   []int64{1},
+			wantVisible: "1",
+			wantRefresh: []int64{1},
+		},
+		{
+			name:        "slice partly used keeps its place",
+			amount:      "1",
+			wantTrades:  []wantTrade{{1, "100", "1"}},
+			wantQueue:   []int64{1, 2},
+			wantVisible: "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hidden := iceberg(limitOrder(1, 1, "sell", "100", "5"), "2")
+			book := newBook(hidden, limitOrder(2, 2, "sell", "100", "1"))
+
+			trades, _ := book.Match(limitOrder(10, 9, "buy", "100", tt.amount), decimal.MustParse("100"))
+
+			checkTrades(t, trades, tt.wantTrades)
+			if got := queue(book, "sell"); !equalIDs(got, tt.wantQueue) {
+				t.Errorf("book = %v, want %v", got, tt.wantQueue)
+			}
+			if !hidden.VisibleAmount.Equal(decimal.MustParse(tt.wantVisible)) {
+				t.Errorf("visible = %s, want %s", hidden.VisibleAmount, tt.wantVisible)
+			}
+
+			var refreshed []int64
+			for _, order := range book.TakeRefreshed() {
+				refreshed = append(refreshed, order.ID)
+			}
+			if !equalIDs(refreshed, tt.wantRefresh) {
+				t.Errorf("refreshed = %v, want %v", refreshed, tt.wantRefresh)
+			}
+		})
+	}
+}
+
+func TestMatchSelfTradePrevention(t *testing.T) {
+	tests := []struct {
+		mode          string
+		wantTrades    []wantTrade
+		wantStatus    string
+		wantRemaining string
+		wantOwnStatus string
+		wantQueue     []int64
+		wantPrevented int
+	}{
+		{
+			mode:          models.STPCancelNewest,
+			wantStatus:    models.StatusCancelled,
+			wantRemaining: "2",
+			wantOwnStatus: models.StatusActive,
+			wantQueue:     []int64{1, 2},
+			wantPrevented: 1,
+		},
+		{
+			mode:          models.STPCancelOldest,
+			wantTrades:    []wantTrade{{2, "100", "1"}},
+			wantStatus:    models.StatusPartiallyFilled,
+			wantRemaining: "1",
+			wantOwnStatus: models.StatusCancelled,
+			wantPrevented: 1,
+		},
+		{
+			mode:          models.STPCancelBoth,
+			wantStatus:    models.StatusCancelled,
+			wantRemaining: "2",
+			wantOwnStatus: models.StatusCancelled,
+			wantQueue:     []int64{2},
+			wantPrevented: 1,
+		},
+		{
+			mode:          models.STPDecrementCancel,
+			wantTrades:    []wantTrade{{2, "100", "1"}},
+			wantStatus:    models.StatusFilled,
+			wantRemaining: "0",
+			wantOwnStatus: models.StatusCancelled,
+			wantPrevented: 1,
+		},
+		{
+			mode:          models.STPNone,
+			wantTrades:    []wantTrade{{1, "100", "1"}, {2, "100", "1"}},
+			wantStatus:    models.StatusFilled,
+			wantRemaining: "0",
+			wantOwnStatus: models.StatusFilled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			own := limitOrder(1, 1, "sell", "100", "1")
+			book := newBook(own, limitOrder(2, 2, "sell", "100", "1"))
+			taker := withSTP(limitOrder(10, 1, "buy", "100", "2"), tt.mode)
+
+			trades, _ := book.Match(taker, decimal.MustParse("100"))
+
+			checkTrades(t, trades, tt.wantTrades)
+			if taker.Status != tt.wantStatus {
+				t.Errorf("taker status = %s, want %s", taker.Status, tt.wantStatus)
+			}
+			if !taker.RemainingAmount.Equal(decimal.MustParse(tt.wantRemaining)) {
+				t.Errorf("taker remaining = %s, want %s", taker.RemainingAmount, tt.wantRemaining)
+			}
+			if own.Status != tt.wantOwnStatus {
+				t.Errorf("own order status = %s, want %s", own.Status, tt.wantOwnStatus)
+			}
+			if got := queue(book, "sell"); !equalIDs(got, tt.wantQueue) {
+				t.Errorf("book = %v, want %v", got, tt.wantQueue)
+			}
+			if got := len(book.TakePrevented()); got != tt.wantPrevented {
+				t.Errorf("prevented = %d, want %d", got, tt.wantPrevented)
+			}
+		})
+	}
+}
+
+func TestFillable(t *testing.T) {
+	tests := []struct {
+		name         string
+		makers       []*models.Order
+		taker        *models.Order
+		limit        string
+		wantFillable string
+		wantWanted   string
+	}{
+		{
+			name: "enough liquidity",
+			makers: []*models.Order{
+				limitOrder(1, 1, "sell", "100", "1"),
+				limitOrder(2, 2, "sell", "101", "1"),
+			},
+			taker:        limitOrder(10, 9, "buy", "101", "1.5"),
+			limit:        "101",
+			wantFillable: "1.5",
+			wantWanted:   "1.5",
+		},
+		{
+			name: "limit leaves some unfilled",
+			makers: []*models.Order{
+				limitOrder(1, 1, "sell", "100", "1"),
+				limitOrder(2, 2, "sell", "102", "1"),
+			},
+			taker:        limitOrder(10, 9, "buy", "101", "2"),
+			limit:        "101",
+			wantFillable: "1",
+			wantWanted:   "2",
+		},
+		{
+			name: "cancel_newest stops at its own order",
+			makers: []*models.Order{
+				limitOrder(1, 1, "sell", "100", "1"),
+				limitOrder(2, 9, "sell", "100", "1"),
+				limitOrder(3, 2, "sell", "100", "1"),
+			},
+			taker:        withSTP(limitOrder(10, 9, "buy", "100", "2"), models.STPCancelNewest),
+			limit:        "100",
+			wantFillable: "1",
+			wantWanted:   "2",
+		},
+		{
+			name: "cancel_oldest skips its own order",
+			makers: []*models.Order{
+				limitOrder(1, 9, "sell", "100", "1"),
+				limitOrder(2, 2, "sell", "100", "1"),
+			},
+			taker:        withSTP(limitOrder(10, 9, "buy", "100", "1.5"), models.STPCancelOldest),
+			limit:        "100",
+			wantFillable: "1",
+			wantWanted:   "1.5",
+		},
+		{
+			// A FOK taker is filled once the smaller own order is cancelled
+			name: "decrement_cancel wants less past a smaller own order",
+			makers: []*models.Order{
+				limitOrder(1, 9, "sell", "100", "0.5"),
+				limitOrder(2, 2, "sell", "100", "1"),
+			},
+			taker:        withSTP(limitOrder(10, 9, "buy", "100", "1.5"), models.STPDecrementCancel),
+			limit:        "100",
+			wantFillable: "1",
+			wantWanted:   "1",
+		},
+		{
+			name: "decrement_cancel stops at a larger own order",
+			makers: []*models.Order{
+				limitOrder(1, 9, "sell", "100", "2"),
+				limitOrder(2, 2, "sell", "100", "1"),
+			},
+			taker:        withSTP(limitOrder(10, 9, "buy", "100", "1.5"), models.STPDecrementCancel),
+			limit:        "100",
+			wantFillable: "0",
+			wantWanted:   "1.5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := newBook(tt.makers...)
+			fillable, wanted := book.Fillable(tt.taker, decimal.MustParse(tt.limit))
+
+			if !fillable.Equal(decimal.MustParse(tt.wantFillable)) || !wanted.Equal(decimal.MustParse(tt.wantWanted)) {
+				t.Errorf("Fillable = %s of %s, want %s of %s", fillable, wanted, tt.wantFillable, tt.wantWanted)
+			}
+
+			// Fillable promises what Match delivers
+			filled := decimal.Zero
+			trades, _ := book.Match(tt.taker, decimal.MustParse(tt.limit))
+			for _, trade := range trades {
+				filled = filled.Add(trade.Amount)
+			}
+			if !filled.Equal(fillable) {
+				t.Errorf("Match filled %s, Fillable reported %s", filled, fillable)
+			}
+		})
+	}
+}
+
+func TestCost(t *testing.T) {
+	tests := []struct {
+		name   string
+		makers []*models.Order
+		taker  *models.Order
+		limit  string
+		want   string
+	}{
+		{
+			name: "sweeps levels at their own prices",
+			makers: []*models.Order{
+				limitOrder(1, 1, "sell", "100", "1"),
+				limitOrder(2, 2, "sell", "101", "1"),
+			},
+			taker: limitOrder(10, 9, "buy", "0", "1.5"),
+			limit: "0",
+			want:  "150.5",
+		},
+		{
+			name: "stops at the limit price",
+			makers: []*models.Order{
+				limitOrder(1, 1, "sell", "100", "1"),
+				limitOrder(2, 2, "sell", "101", "1"),
+			},
+			taker: limitOrder(10, 9, "buy", "100", "1.5"),
+			limit: "100",
+			want:  "100",
+		},
+		{
+			name: "cancel_oldest skips its own order",
+			makers: []*models.Order{
+				limitOrder(1, 9, "sell", "100", "1"),
+				limitOrder(2, 2, "sell", "101", "1"),
+			},
+			taker: withSTP(limitOrder(10, 9, "buy", "0", "1"), models.STPCancelOldest),
+			limit: "0",
+			want:  "101",
+		},
+		{
+			name: "decrement_cancel pays for less",
+			makers: []*models.Order{
+				limitOrder(1, 9, "sell", "100", "0.5"),
+				limitOrder(2, 2, "sell", "101", "1"),
+			},
+			taker: withSTP(limitOrder(10, 9, "buy", "0", "1"), models.STPDecrementCancel),
+			limit: "0",
+			want:  "50.5",
+		},
+		{
+			name: "cancel_newest pays nothing past its own order",
+			makers: []*models.Order{
+				limitOrder(1, 9, "sell", "100", "1"),
+				limitOrder(2, 2, "sell", "101", "1"),
+			},
+			taker: withSTP(limitOrder(10, 9, "buy", "0", "1"), models.STPCancelNewest),
+			limit: "0",
+			want:  "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := newBook(tt.makers...)
+			if got := book.Cost(tt.taker, decimal.MustParse(tt.limit)); !got.Equal(decimal.MustParse(tt.want)) {
+				t.Errorf("Cost = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
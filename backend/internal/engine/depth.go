@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"sort"
+)
+
+// depth is the aggregated book of a market as last announced, by price
+type depth struct {
+	bids map[string]models.PriceLevel
+	asks map[string]models.PriceLevel
+}
+
+// Depth returns the amount shown at each price of a side, best price first.
+// Iceberg orders only count with their current slice.
+func (b *Book) Depth(orderType string) []models.PriceLevel {
+	levels := *b.side(orderType)
+	depth := make([]models.PriceLevel, 0, len(levels))
+	for _, lvl := range levels {
+		total := decimal.Zero
+		for _, order := range lvl.orders {
+			total = total.Add(order.Shown())
+		}
+		if total.IsPositive() {
+			depth = append(depth, models.PriceLevel{Price: lvl.price, Amount: total})
+		}
+	}
+	return depth
+}
+
+// publishDepth announces the prices of a market's aggregated book that have
+// changed since it was last announced
+func (e *Engine) publishDepth(symbol string) {
+	last, ok := e.depths[symbol]
+	if !ok {
+		last = &depth{
+			bids: make(map[string]models.PriceLevel),
+			asks: make(map[string]models.PriceLevel),
+		}
+		e.depths[symbol] = last
+	}
+
+	book := e.book(symbol)
+	update := models.DepthUpdate{
+		Symbol: symbol,
+		Bids:   diffLevels(last.bids, book.Depth("buy")),
+		Asks:   diffLevels(last.asks, book.Depth("sell")),
+	}
+	if len(update.Bids) == 0 && len(update.Asks) == 0 {
+		return
+	}
+	sortLevels(update.Bids, true)
+	sortLevels(update.Asks, false)
+
+	e.hub.BroadcastDepth(&update)
+}
+
+// publishMarketData announces what changed in a market's aggregated book and
+// ticker
+func (e *Engine) publishMarketData(symbol string) {
+	e.publishDepth(symbol)
+	e.publishTicker(symbol)
+}
+
+// diffLevels returns the levels of current whose amount differs from last,
+// plus a zero amount for each price of last that is gone, and brings last up
+// to date
+func diffLevels(last map[string]models.PriceLevel, current []models.PriceLevel) []models.PriceLevel {
+	changed := []models.PriceLevel{}
+	seen := make(map[string]bool, len(current))
+	for _, lvl := range current {
+		key := lvl.Price.String()
+		seen[key] = true
+		if prev, ok := last[key]; !ok || !prev.Amount.Equal(lvl.Amount) {
+			changed = append(changed, lvl)
+			last[key] = lvl
+		}
+	}
+	for key, prev := range last {
+		if !seen[key] {
+			changed = append(changed, models.PriceLevel{Price: prev.Price, Amount: decimal.Zero})
+			delete(last, key)
+		}
+	}
+	return changed
+}
+
+// sortLevels orders levels best price first: highest first for bids, lowest
+// first for asks
+func sortLevels(levels []models.PriceLevel, descending bool) {
+	sort.Slice(levels, func(i, j int) bool {
+		if descending {
+			return levels[i].Price.GreaterThan(levels[j].Price)
+		}
+		return levels[i].Price.LessThan(levels[j].Price)
+	})
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"testing"
+)
+
+func priceLevel(price, amount string) models.PriceLevel {
+	return models.PriceLevel{Price: decimal.MustParse(price), Amount: decimal.MustParse(amount)}
+}
+
+func levelMap(levels ...models.PriceLevel) map[string]models.PriceLevel {
+	m := make(map[string]models.PriceLevel, len(levels))
+	for _, lvl := range levels {
+		m[lvl.Price.String()] = lvl
+	}
+	return m
+}
+
+func checkLevels(t *testing.T, what string, got, want []models.PriceLevel) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s = %v, want %v", what, got, want)
+	}
+	for i := range want {
+		if !got[i].Price.Equal(want[i].Price) || !got[i].Amount.Equal(want[i].Amount) {
+			t.Errorf("%s[%d] = %s @ %s, want %s @ %s", what, i, got[i].Amount, got[i].Price, want[i].Amount, want[i].Price)
+		}
+	}
+}
+
+func TestDiffLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		last    []models.PriceLevel
+		current []models.PriceLevel
+		want    []models.PriceLevel
+	}{
+		{
+			name:    "unchanged",
+			last:    []models.PriceLevel{priceLevel("100", "1"), priceLevel("99", "2")},
+			current: []models.PriceLevel{priceLevel("100", "1"), priceLevel("99", "2")},
+			want:    []models.PriceLevel{},
+		},
+		{
+			name:    "new level",
+			last:    []models.PriceLevel{priceLevel("100", "1")},
+			current: []models.PriceLevel{priceLevel("101", "0.5"), priceLevel("100", "1")},
+			want:    []models.PriceLevel{priceLevel("101", "0.5")},
+		},
+		{
+			name:    "changed amount",
+			last:    []models.PriceLevel{priceLevel("100", "1"), priceLevel("99", "2")},
+			current: []models.PriceLevel{priceLevel("100", "1.5"), priceLevel("99", "2")},
+			want:    []models.PriceLevel{priceLevel("100", "1.5")},
+		},
+		{
+			name:    "removed level",
+			last:    []models.PriceLevel{priceLevel("100", "1"), priceLevel("99", "2")},
+			current: []models.PriceLevel{priceLevel("99", "2")},
+			want:    []models.PriceLevel{priceLevel("100", "0")},
+		},
+		{
+			name:    "removed, changed and new together",
+			last:    []models.PriceLevel{priceLevel("100", "1"), priceLevel("99", "2"), priceLevel("98", "3")},
+			current: []models.PriceLevel{priceLevel("99", "2.5"), priceLevel("98", "3"), priceLevel("97", "1")},
+			want:    []models.PriceLevel{priceLevel("100", "0"), priceLevel("99", "2.5"), priceLevel("97", "1")},
+		},
+		{
+			name:    "book emptied",
+			last:    []models.PriceLevel{priceLevel("100", "1"), priceLevel("99", "2")},
+			current: []models.PriceLevel{},
+			want:    []models.PriceLevel{priceLevel("100", "0"), priceLevel("99", "0")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			last := levelMap(tt.last...)
+			got := diffLevels(last, tt.current)
+			sortLevels(got, true)
+			checkLevels(t, "diff", got, tt.want)
+
+			// last now matches current, so the same book yields no diff
+			if len(last) != len(tt.current) {
+				t.Errorf("last holds %d levels, want %d", len(last), len(tt.current))
+			}
+			if again := diffLevels(last, tt.current); len(again) != 0 {
+				t.Errorf("second diff = %v, want none", again)
+			}
+		})
+	}
+}
+
+func TestBookDepth(t *testing.T) {
+	book := newBook(
+		limitOrder(1, 1, "buy", "99", "1"),
+		iceberg(limitOrder(2, 2, "buy", "100", "10"), "2"),
+		limitOrder(3, 3, "buy", "100", "0.5"),
+		limitOrder(4, 4, "sell", "101", "3"),
+	)
+
+	// The iceberg only counts with its visible slice
+	checkLevels(t, "bids", book.Depth("buy"), []models.PriceLevel{priceLevel("100", "2.5"), priceLevel("99", "1")})
+	checkLevels(t, "asks", book.Depth("sell"), []models.PriceLevel{priceLevel("101", "3")})
+}
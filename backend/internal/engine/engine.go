@@ -0,0 +1,759 @@
+package engine
+
+import (
+	"context"
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"crypto-orderbook/internal/repository"
+	"crypto-orderbook/internal/websocket"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Engine serializes order entry: every incoming order is matched against the
+// in-memory book of its market and the result is written to the database in
+// a single transaction before it is broadcast.
+type Engine struct {
+	mu         sync.Mutex
+	db         *pgxpool.Pool
+	hub        *websocket.Hub
+	books      map[string]*Book           // by market symbol
+	markets    map[string]*models.Market  // by market symbol
+	lastPrices map[string]decimal.Decimal // last trade price by market symbol
+	tickers    map[string]models.Ticker   // last announced ticker by market symbol
+	depths     map[string]*depth          // last announced aggregated book by market symbol
+
+	// Balances changed by the operation under way, announced to their users
+	// once it commits
+	balances map[balanceKey]models.Balance
+}
+
+// Result is the outcome of placing an order
+type Result struct {
+	Order  models.Order   `json:"order"`
+	Trades []models.Trade `json:"trades"`
+	Makers []models.Order `json:"makers"`
+
+	// Resting orders of the same groups cancelled along the way, and the
+	// executions of bracket legs activated by a filled entry
+	Cancelled []models.Order `json:"cancelled,omitempty"`
+	Activated []*Result      `json:"activated,omitempty"`
+
+	// Reduce-only orders shrunk, or cancelled while waiting for a trigger,
+	// because the trades moved their user's position
+	Reduced []models.Order `json:"reduced,omitempty"`
+
+	// Trailing stops whose trigger moved with the trades, and self-trades
+	// that were prevented; only their owners are told
+	Trailed   []models.Order               `json:"trailed,omitempty"`
+	Prevented []models.SelfTradePrevention `json:"prevented,omitempty"`
+
+	// Hidden keeps Order itself from being broadcast, as for a stop order
+	// that has not triggered yet
+	Hidden bool `json:"-"`
+}
+
+func NewEngine(db *pgxpool.Pool, hub *websocket.Hub) *Engine {
+	return &Engine{
+		db:         db,
+		hub:        hub,
+		books:      make(map[string]*Book),
+		markets:    make(map[string]*models.Market),
+		lastPrices: make(map[string]decimal.Decimal),
+		tickers:    make(map[string]models.Ticker),
+		depths:     make(map[string]*depth),
+		balances:   make(map[balanceKey]models.Balance),
+	}
+}
+
+// Load rebuilds the in-memory books, markets and last trade prices from the
+// database. Orders of cancel-on-disconnect sessions that no longer exist,
+// such as every session from before a restart, are then cancelled.
+func (e *Engine) Load(ctx context.Context) error {
+	e.mu.Lock()
+	err := e.load(ctx)
+	e.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	sessions, err := repository.NewOrderRepository(e.db).GetSessions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load order book: %w", err)
+	}
+	for sessionID, userID := range sessions {
+		if !e.hub.HasSession(sessionID, userID) {
+			e.CancelSession(ctx, userID, sessionID)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) load(ctx context.Context) error {
+	orders, err := repository.NewOrderRepository(e.db).GetOpenOrders(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load order book: %w", err)
+	}
+
+	lastPrices, err := repository.NewTradeRepository(e.db).LastPrices(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load order book: %w", err)
+	}
+
+	markets, err := repository.NewMarketRepository(e.db).GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load order book: %w", err)
+	}
+
+	e.markets = make(map[string]*models.Market)
+	for i := range markets {
+		e.markets[markets[i].Symbol] = &markets[i]
+	}
+
+	e.books = make(map[string]*Book)
+	e.lastPrices = lastPrices
+	for i := range orders {
+		if orders[i].Status == models.StatusPendingTrigger {
+			e.book(orders[i].Symbol).AddStop(&orders[i])
+		} else {
+			e.book(orders[i].Symbol).Add(&orders[i])
+		}
+	}
+
+	// Subscribers see whatever the books now hold, after a restart or a
+	// resync alike
+	for symbol := range e.markets {
+		e.publishMarketData(symbol)
+	}
+
+	log.Printf("Order books loaded with %d open orders", len(orders))
+	return nil
+}
+
+// Symbols returns the symbols of the loaded markets
+func (e *Engine) Symbols() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	symbols := make([]string, 0, len(e.markets))
+	for symbol := range e.markets {
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+// book returns the book of a market, creating it on first use
+func (e *Engine) book(symbol string) *Book {
+	book, ok := e.books[symbol]
+	if !ok {
+		book = NewBook()
+		e.books[symbol] = book
+	}
+	return book
+}
+
+// PlaceOrder stores a new order, matches it against the book and persists
+// every resulting change atomically. Whatever is left of a GTC or GTD limit
+// order rests in the book; the rest of any other order is cancelled. Stop
+// orders are held until the last trade price reaches their trigger. Orders
+// failing a post-only, reduce-only, trigger or funds check are rejected with
+// an *OrderError and never stored.
+func (e *Engine) PlaceOrder(ctx context.Context, market *models.Market, order *models.Order) (*Result, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.checkMarket(order.Symbol); err != nil {
+		return nil, err
+	}
+	if err := e.checkSession(order); err != nil {
+		return nil, err
+	}
+	book := e.book(order.Symbol)
+	if err := checkPostOnly(market, book, order); err != nil {
+		return nil, err
+	}
+	lastPrice, traded := e.lastPrices[order.Symbol]
+	if err := startTrailing(order, lastPrice, traded); err != nil {
+		return nil, err
+	}
+	if err := checkTrigger(order, lastPrice, traded); err != nil {
+		return nil, err
+	}
+
+	var result *Result
+	err := repository.WithTx(ctx, e.db, func(tx pgx.Tx) error {
+		if err := checkReduceOnly(ctx, repository.NewTradeRepository(tx), book, order); err != nil {
+			return err
+		}
+		if err := repository.NewOrderRepository(tx).Create(ctx, order); err != nil {
+			return err
+		}
+
+		if order.Status == models.StatusPendingTrigger {
+			pending := *order
+			book.AddStop(&pending)
+			result = &Result{Order: *order, Hidden: true}
+			return nil
+		}
+
+		var err error
+		result, err = e.execute(ctx, tx, book, order)
+		return err
+	})
+	if err != nil {
+		return nil, e.resync(err)
+	}
+
+	e.broadcast(result)
+	e.runTriggers(ctx, order.Symbol)
+
+	return result, nil
+}
+
+// execute reserves the funds for an order that is already stored, matches
+// it against the book and writes the outcome within tx. It fails with an
+// *OrderError, before touching the book, if the user cannot pay for the
+// order.
+func (e *Engine) execute(ctx context.Context, tx pgx.Tx, book *Book, order *models.Order) (*Result, error) {
+	orderRepo := repository.NewOrderRepository(tx)
+	tradeRepo := repository.NewTradeRepository(tx)
+
+	if err := e.lockFunds(ctx, tx, book, order); err != nil {
+		return nil, err
+	}
+
+	var trades []models.Trade
+	var makers []*models.Order
+
+	limit := executionLimit(book, order)
+
+	// A fill-or-kill order only touches the book if it can fill completely,
+	// less whatever self-trade prevention would take off it
+	fillable := order.TimeInForce != models.TimeInForceFOK
+	if !fillable {
+		filled, wanted := book.Fillable(order, limit)
+		fillable = filled.Equal(wanted)
+	}
+	if fillable {
+		trades, makers = book.Match(order, limit)
+	}
+
+	if order.IsOpen() && !order.CanRest() {
+		order.Status = models.StatusCancelled
+	}
+	if order.IsOpen() && order.IsIceberg() {
+		order.VisibleAmount = decimal.Min(order.DisplayAmount, order.RemainingAmount)
+	}
+
+	if err := e.chargeFees(ctx, tx, trades); err != nil {
+		return nil, err
+	}
+	for i := range trades {
+		if err := tradeRepo.Create(ctx, &trades[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Trades are paid for out of the reserved funds; whatever an order no
+	// longer needs is released
+	involved := map[int64]*models.Order{order.ID: order}
+	for _, maker := range makers {
+		involved[maker.ID] = maker
+	}
+	if err := e.settleTrades(ctx, tx, trades, involved); err != nil {
+		return nil, err
+	}
+	for _, o := range append([]*models.Order{order}, makers...) {
+		if err := e.lockFunds(ctx, tx, book, o); err != nil {
+			return nil, err
+		}
+	}
+
+	if order.IsOpen() {
+		resting := *order
+		book.Add(&resting)
+	}
+
+	if err := orderRepo.UpdateExecution(ctx, order); err != nil {
+		return nil, err
+	}
+	for _, maker := range makers {
+		if err := orderRepo.UpdateExecution(ctx, maker); err != nil {
+			return nil, err
+		}
+	}
+	for _, refreshed := range book.TakeRefreshed() {
+		if err := orderRepo.Requeue(ctx, refreshed.ID); err != nil {
+			return nil, err
+		}
+	}
+	if len(trades) > 0 {
+		e.lastPrices[order.Symbol] = trades[len(trades)-1].Price
+	}
+
+	result := &Result{Order: *order, Trades: trades}
+	for _, maker := range makers {
+		result.Makers = append(result.Makers, *maker)
+	}
+
+	if prevented := book.TakePrevented(); len(prevented) > 0 {
+		if err := e.recordPreventions(ctx, tx, book, involved, prevented, result); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, trailed := range book.Trail(trades) {
+		if err := orderRepo.UpdateTrigger(ctx, trailed); err != nil {
+			return nil, err
+		}
+		result.Trailed = append(result.Trailed, *trailed)
+	}
+
+	if err := e.settleGroup(ctx, tx, book, order, result); err != nil {
+		return nil, err
+	}
+	for _, maker := range makers {
+		if err := e.settleGroup(ctx, tx, book, maker, result); err != nil {
+			return nil, err
+		}
+	}
+
+	// A reduce-only order may now be larger than the position it reduces;
+	// that includes the rest of this order
+	for _, userID := range tradeUsers(trades) {
+		if err := e.trimReduceOnly(ctx, tx, book, userID, order.Symbol, result); err != nil {
+			return nil, err
+		}
+	}
+	for _, changed := range slices.Concat(result.Cancelled, result.Reduced) {
+		if changed.ID == order.ID {
+			*order = changed
+			result.Order = changed
+		}
+	}
+
+	return result, nil
+}
+
+// tradeUsers returns the users on either side of trades, each once
+func tradeUsers(trades []models.Trade) []int64 {
+	var users []int64
+	for i := range trades {
+		for _, userID := range []int64{trades[i].MakerUserID, trades[i].TakerUserID} {
+			if !slices.Contains(users, userID) {
+				users = append(users, userID)
+			}
+		}
+	}
+	return users
+}
+
+// runTriggers activates the stop orders of a market that the last trade
+// price has reached, oldest first. Each activation may trade and move the
+// price again, so this keeps going until no stop order fires. A stop leg of
+// a group cancels the rest of its group as it fires, which also frees the
+// funds it needs; a stop order its user cannot pay for is cancelled.
+func (e *Engine) runTriggers(ctx context.Context, symbol string) {
+	book := e.book(symbol)
+
+	for {
+		lastPrice, ok := e.lastPrices[symbol]
+		if !ok {
+			return
+		}
+		order := book.NextTriggered(lastPrice)
+		if order == nil {
+			return
+		}
+
+		order.Status = models.StatusActive
+
+		var result *Result
+		err := repository.WithTx(ctx, e.db, func(tx pgx.Tx) error {
+			siblings := &Result{}
+			if order.GroupID != nil {
+				if err := e.cancelSiblings(ctx, tx, book, order, siblings); err != nil {
+					return err
+				}
+			}
+
+			fits, err := fitReduceOnly(ctx, tx, book, order)
+			if err != nil {
+				return err
+			}
+			if fits {
+				result, err = e.executeOrCancel(ctx, tx, book, order)
+				if err != nil {
+					return err
+				}
+			} else {
+				result = &Result{Order: *order}
+			}
+			result.Cancelled = append(siblings.Cancelled, result.Cancelled...)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error triggering stop order %d: %v", order.ID, e.resync(err))
+			return
+		}
+
+		e.broadcast(result)
+	}
+}
+
+// executeOrCancel executes an order that has just gone live on its own,
+// such as a triggered stop or an activated bracket leg, and cancels it
+// instead if its user cannot pay for it
+func (e *Engine) executeOrCancel(ctx context.Context, tx pgx.Tx, book *Book, order *models.Order) (*Result, error) {
+	result, err := e.execute(ctx, tx, book, order)
+	var orderErr *OrderError
+	if !errors.As(err, &orderErr) {
+		return result, err
+	}
+
+	order.Status = models.StatusCancelled
+	if err := repository.NewOrderRepository(tx).UpdateExecution(ctx, order); err != nil {
+		return nil, err
+	}
+	return &Result{Order: *order}, nil
+}
+
+// resync passes rejections through untouched. After any other failure the
+// book may already reflect a rolled back match, so it starts over from what
+// is actually committed.
+func (e *Engine) resync(err error) error {
+	// Whatever was rolled back did not change any balance
+	clear(e.balances)
+
+	var orderErr *OrderError
+	if errors.As(err, &orderErr) {
+		return err
+	}
+
+	if loadErr := e.load(context.Background()); loadErr != nil {
+		log.Printf("Error reloading order book: %v", loadErr)
+	}
+	return err
+}
+
+// executionLimit is the worst price an order may trade at. For a market order
+// it is the tighter of its worst price and its slippage bound around the best
+// opposite price, or zero if neither was given.
+func executionLimit(book *Book, order *models.Order) decimal.Decimal {
+	if order.OrderKind != models.KindMarket {
+		return order.Price
+	}
+
+	limit := order.WorstPrice
+	best, ok := book.BestPrice(opposite(order.OrderType))
+	if !ok || order.MaxSlippage.IsZero() {
+		return limit
+	}
+
+	one := decimal.NewFromInt(1)
+	if order.OrderType == "buy" {
+		bound := best.Mul(one.Add(order.MaxSlippage))
+		if limit.IsZero() || bound.LessThan(limit) {
+			limit = bound
+		}
+	} else {
+		bound := best.Mul(one.Sub(order.MaxSlippage))
+		if limit.IsZero() || bound.GreaterThan(limit) {
+			limit = bound
+		}
+	}
+
+	return limit
+}
+
+// CancelOrder cancels one of the user's open orders and takes it out of the
+// book. Cancelling a leg of a group cancels the whole group; cancelling a
+// bracket entry that has partly filled activates its legs for what filled.
+func (e *Engine) CancelOrder(ctx context.Context, orderID, userID int64) (*models.Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var order *models.Order
+	var result *Result
+	var resting bool
+	err := repository.WithTx(ctx, e.db, func(tx pgx.Tx) error {
+		var err error
+		order, err = repository.NewOrderRepository(tx).Delete(ctx, orderID, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := e.releaseFunds(ctx, tx, order); err != nil {
+			return err
+		}
+
+		book := e.book(order.Symbol)
+		result = &Result{Order: *order, Hidden: true}
+		_, resting = book.orders[order.ID]
+		book.Remove(order.ID)
+
+		if order.GroupID == nil {
+			return nil
+		}
+		if order.GroupRole == models.RoleEntry {
+			return e.settleGroup(ctx, tx, book, order, result)
+		}
+		return e.cancelSiblings(ctx, tx, book, order, result)
+	})
+	if errors.Is(err, repository.ErrOrderNotFound) || errors.Is(err, repository.ErrOrderNotOpen) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, e.resync(err)
+	}
+
+	// Orders that never showed in the book are not announced
+	if resting {
+		e.hub.BroadcastOrderCancelled(order)
+	}
+	e.broadcast(result)
+	if len(result.Activated) > 0 {
+		e.runTriggers(ctx, order.Symbol)
+	}
+
+	return order, nil
+}
+
+// CancelAll cancels every unfinished order of the user that matches filter
+// in a single transaction. Groups are settled as if each order had been
+// cancelled on its own: a bracket entry that has partly filled activates its
+// legs for what filled, and any other group is cancelled whole, whatever
+// side its other legs are on. The orders that were visible in the book are
+// announced in one message per market.
+func (e *Engine) CancelAll(ctx context.Context, userID int64, filter repository.CancelFilter) ([]models.Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var cancelled []models.Order
+	var visible map[string][]models.Order
+	result := &Result{Hidden: true}
+	err := repository.WithTx(ctx, e.db, func(tx pgx.Tx) error {
+		orderRepo := repository.NewOrderRepository(tx)
+		orders, err := orderRepo.DeleteAll(ctx, userID, filter)
+		if err != nil {
+			return err
+		}
+
+		visible = make(map[string][]models.Order)
+		var entries []models.Order
+		for i := 0; i < len(orders); i++ {
+			if err := e.releaseFunds(ctx, tx, &orders[i]); err != nil {
+				return err
+			}
+			book := e.book(orders[i].Symbol)
+			if _, resting := book.orders[orders[i].ID]; resting {
+				visible[orders[i].Symbol] = append(visible[orders[i].Symbol], orders[i])
+			}
+			book.Remove(orders[i].ID)
+
+			if orders[i].GroupID == nil {
+				continue
+			}
+			if orders[i].GroupRole == models.RoleEntry && orders[i].FilledAmount.IsPositive() {
+				entries = append(entries, orders[i])
+				continue
+			}
+			siblings, err := orderRepo.CancelSiblings(ctx, *orders[i].GroupID, orders[i].ID)
+			if err != nil {
+				return err
+			}
+			orders = append(orders, siblings...)
+		}
+
+		// Legs only go live once everything cancelled is out of the book
+		for i := range entries {
+			if err := e.activateLegs(ctx, tx, e.book(entries[i].Symbol), &entries[i], result); err != nil {
+				return err
+			}
+		}
+
+		cancelled = orders
+		return nil
+	})
+	if err != nil {
+		return nil, e.resync(err)
+	}
+
+	for symbol, orders := range visible {
+		e.hub.BroadcastOrdersCancelled(symbol, orders)
+		e.publishMarketData(symbol)
+	}
+	for i := range cancelled {
+		e.hub.SendOrderUpdate(&cancelled[i])
+	}
+	e.broadcast(result)
+	for _, activated := range result.Activated {
+		e.runTriggers(ctx, activated.Order.Symbol)
+	}
+
+	return cancelled, nil
+}
+
+// CancelSession cancels the orders placed with a cancel-on-disconnect
+// session once it has ended
+func (e *Engine) CancelSession(ctx context.Context, userID int64, sessionID string) {
+	cancelled, err := e.CancelAll(ctx, userID, repository.CancelFilter{SessionID: sessionID})
+	if err != nil {
+		log.Printf("Error cancelling orders of session %s: %v", sessionID, err)
+		return
+	}
+
+	if len(cancelled) > 0 {
+		log.Printf("Cancelled %d orders of disconnected session %s", len(cancelled), sessionID)
+	}
+}
+
+// RunExpiry expires good-til-date orders every interval until ctx is done
+func (e *Engine) RunExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := e.ExpireOrders(ctx, now); err != nil {
+				log.Printf("Error expiring orders: %v", err)
+			}
+		}
+	}
+}
+
+// ExpireOrders moves every resting or stop order whose expiry is not after
+// now to the expired status, takes it out of the book and settles its group
+func (e *Engine) ExpireOrders(ctx context.Context, now time.Time) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var due []*models.Order
+	var ids []int64
+	for _, book := range e.books {
+		for _, order := range book.Expired(now) {
+			due = append(due, order)
+			ids = append(ids, order.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var expired []models.Order
+	result := &Result{Hidden: true}
+	err := repository.WithTx(ctx, e.db, func(tx pgx.Tx) error {
+		var err error
+		expired, err = repository.NewOrderRepository(tx).Expire(ctx, ids)
+		if err != nil {
+			return err
+		}
+
+		// Orders the database no longer considers open are dropped as well
+		for _, order := range due {
+			e.book(order.Symbol).Remove(order.ID)
+		}
+		for i := range expired {
+			if err := e.releaseFunds(ctx, tx, &expired[i]); err != nil {
+				return err
+			}
+			if err := e.settleGroup(ctx, tx, e.book(expired[i].Symbol), &expired[i], result); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return e.resync(err)
+	}
+
+	for i := range expired {
+		e.hub.BroadcastOrderExpired(&expired[i])
+		e.hub.SendOrderUpdate(&expired[i])
+		e.publishMarketData(expired[i].Symbol)
+	}
+	e.broadcast(result)
+	for _, activated := range result.Activated {
+		e.runTriggers(ctx, activated.Order.Symbol)
+	}
+
+	return nil
+}
+
+// broadcast announces a result: trades first, then the makers they filled,
+// then the order itself and whatever it did to its group, and finally the
+// new aggregated book and ticker of every market it touched. The users
+// involved are also told about their fills, every order of theirs that
+// changed, hidden or not, and their new balances.
+func (e *Engine) broadcast(result *Result) {
+	for i := range result.Trades {
+		e.hub.BroadcastTrade(&result.Trades[i])
+	}
+
+	for i := range result.Makers {
+		e.hub.BroadcastOrderUpdate(&result.Makers[i])
+	}
+
+	if !result.Hidden {
+		if result.Order.IsOpen() {
+			e.hub.BroadcastOrder(&result.Order)
+		} else {
+			e.hub.BroadcastOrderUpdate(&result.Order)
+		}
+	}
+
+	for i := range result.Cancelled {
+		e.hub.BroadcastOrderCancelled(&result.Cancelled[i])
+	}
+	for i := range result.Reduced {
+		if result.Reduced[i].IsOpen() {
+			e.hub.BroadcastOrderUpdate(&result.Reduced[i])
+		}
+	}
+
+	for i := range result.Trades {
+		for _, fill := range result.Trades[i].Fills() {
+			e.hub.SendFill(&fill)
+		}
+	}
+	for i := range result.Makers {
+		e.hub.SendOrderUpdate(&result.Makers[i])
+	}
+	if result.Order.ID != 0 {
+		e.hub.SendOrderUpdate(&result.Order)
+	}
+	for i := range result.Cancelled {
+		e.hub.SendOrderUpdate(&result.Cancelled[i])
+	}
+	for i := range result.Reduced {
+		e.hub.SendOrderUpdate(&result.Reduced[i])
+	}
+	for i := range result.Trailed {
+		e.hub.SendTriggerUpdate(&result.Trailed[i])
+	}
+	for i := range result.Prevented {
+		e.hub.SendSelfTradePrevented(&result.Prevented[i])
+	}
+	e.publishBalances()
+	for _, activated := range result.Activated {
+		e.broadcast(activated)
+	}
+
+	if result.Order.Symbol != "" {
+		e.publishMarketData(result.Order.Symbol)
+	}
+	for i := range result.Cancelled {
+		e.publishMarketData(result.Cancelled[i].Symbol)
+	}
+}
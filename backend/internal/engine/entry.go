@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"context"
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"crypto-orderbook/internal/repository"
+)
+
+// Rejection codes of the order-entry checks
+const (
+	CodePostOnlyWouldTake  = "POST_ONLY_WOULD_TAKE"
+	CodeReduceOnlyIncrease = "REDUCE_ONLY_WOULD_INCREASE"
+	CodeStopWouldTrigger   = "STOP_WOULD_TRIGGER"
+	CodeSessionNotActive   = "SESSION_NOT_ACTIVE"
+	CodeMarketNotLoaded    = "MARKET_NOT_LOADED"
+)
+
+// checkMarket makes sure the engine knows a market. Markets are loaded at
+// startup, so one listed later cannot be traded until the next restart.
+func (e *Engine) checkMarket(symbol string) error {
+	if _, ok := e.markets[symbol]; ok {
+		return nil
+	}
+	return reject(CodeMarketNotLoaded, "Market %s is not open for trading yet", symbol)
+}
+
+// checkSession makes sure an order placed with a cancel-on-disconnect
+// session is placed while the session has a client connected. The caller
+// holds e.mu, which CancelSession waits for once the session has ended, so
+// an order let through here is always cancelled with its session.
+func (e *Engine) checkSession(order *models.Order) error {
+	if order.SessionID == "" || e.hub.SessionActive(order.SessionID, order.UserID) {
+		return nil
+	}
+	return reject(CodeSessionNotActive, "Unknown or disconnected session")
+}
+
+// checkPostOnly makes sure a post-only order cannot take liquidity. A
+// crossing order is either rejected or, if it asked for it, moved one tick
+// behind the best opposite price and checked against the market's limits
+// again.
+func checkPostOnly(market *models.Market, book *Book, order *models.Order) error {
+	if !order.PostOnly {
+		return nil
+	}
+
+	best, ok := book.BestPrice(opposite(order.OrderType))
+	if !ok || !crosses(order.OrderType, order.Price, best) {
+		return nil
+	}
+
+	if !order.RepriceOnCross {
+		return reject(CodePostOnlyWouldTake, "Post-only order would trade against the book at %s", best)
+	}
+
+	if order.OrderType == "buy" {
+		order.Price = best.Sub(market.TickSize)
+		if !order.Price.IsPositive() {
+			return reject(CodePostOnlyWouldTake, "Post-only buy order cannot be priced below the best ask of %s", best)
+		}
+	} else {
+		order.Price = best.Add(market.TickSize)
+	}
+
+	// A lower buy price can take the order under the minimum notional and a
+	// higher sell price over the maximum
+	return ValidateOrder(market, order)
+}
+
+// checkReduceOnly makes sure a reduce-only order, together with the user's
+// other open reduce-only orders on the same side, cannot take the net
+// position past zero
+func checkReduceOnly(ctx context.Context, trades *repository.TradeRepository, book *Book, order *models.Order) error {
+	if !order.ReduceOnly {
+		return nil
+	}
+
+	reducible, err := reducibleAmount(ctx, trades, book, order)
+	if err != nil {
+		return err
+	}
+	if order.RemainingAmount.GreaterThan(reducible) {
+		return reject(CodeReduceOnlyIncrease, "Reduce-only order would increase the position; at most %s can be reduced", reducible)
+	}
+
+	return nil
+}
+
+// reducibleAmount is how much of a reduce-only order may still execute: the
+// user's net position on the order's side, less their other open reduce-only
+// orders on that side, and never below zero
+func reducibleAmount(ctx context.Context, trades *repository.TradeRepository, book *Book, order *models.Order) (decimal.Decimal, error) {
+	position, err := trades.NetPosition(ctx, order.UserID, order.Symbol)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	// Selling reduces a long position, buying reduces a short one
+	reducible := position
+	if order.OrderType == "buy" {
+		reducible = position.Neg()
+	}
+	for _, orders := range []map[int64]*models.Order{book.orders, book.pending} {
+		for _, resting := range orders {
+			if resting.ID != order.ID && resting.UserID == order.UserID && resting.ReduceOnly && resting.OrderType == order.OrderType {
+				reducible = reducible.Sub(resting.RemainingAmount)
+			}
+		}
+	}
+
+	return decimal.Max(reducible, decimal.Zero), nil
+}
+
+// checkTrigger rejects a stop order that the last trade price has already
+// triggered, since it would execute straight away. Bracket legs are only
+// checked once their entry fills.
+func checkTrigger(order *models.Order, lastPrice decimal.Decimal, traded bool) error {
+	if order.Status != models.StatusPendingTrigger || !traded || !triggered(order, lastPrice) {
+		return nil
+	}
+
+	return reject(CodeStopWouldTrigger, "Stop order would trigger immediately; the last price is %s", lastPrice)
+}
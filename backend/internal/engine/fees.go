@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"context"
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"crypto-orderbook/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// chargeFees sets the fee of both sides of each trade: the market's maker
+// or taker rate, less the discount of the user's fee tier, of what that side
+// receives. The buyer pays in the base asset and the seller in the quote
+// asset.
+func (e *Engine) chargeFees(ctx context.Context, tx pgx.Tx, trades []models.Trade) error {
+	if len(trades) == 0 {
+		return nil
+	}
+
+	userIDs := make([]int64, 0, 2*len(trades))
+	for _, trade := range trades {
+		userIDs = append(userIDs, trade.MakerUserID, trade.TakerUserID)
+	}
+	tiers, err := repository.NewFeeRepository(tx).GetUserTiers(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range trades {
+		trade := &trades[i]
+		market := e.markets[trade.Symbol]
+		makerTier, takerTier := tiers[trade.MakerUserID], tiers[trade.TakerUserID]
+
+		trade.MakerFee, trade.MakerFeeAsset = tradeFee(trade, market, trade.AggressorSide == "sell", makerTier.MakerRate(market))
+		trade.TakerFee, trade.TakerFeeAsset = tradeFee(trade, market, trade.AggressorSide == "buy", takerTier.TakerRate(market))
+	}
+
+	return nil
+}
+
+// tradeFee is the fee at rate of one side of a trade and the asset it is
+// paid in
+func tradeFee(trade *models.Trade, market *models.Market, buyer bool, rate decimal.Decimal) (decimal.Decimal, string) {
+	if buyer {
+		return trade.Amount.Mul(rate), market.BaseAsset
+	}
+	return trade.Price.Mul(trade.Amount).Mul(rate), market.QuoteAsset
+}
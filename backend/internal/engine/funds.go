@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"context"
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"crypto-orderbook/internal/repository"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CodeInsufficientFunds rejects an order its user cannot pay for
+const CodeInsufficientFunds = "INSUFFICIENT_FUNDS"
+
+// requiredFunds is what an order has to have reserved right now: the price
+// times the remaining amount of quote asset for a buy with a price, the
+// cost of sweeping the book for one without, and the remaining amount of
+// base asset for a sell. Orders that are no longer live need nothing.
+func requiredFunds(book *Book, order *models.Order) decimal.Decimal {
+	if !order.IsOpen() {
+		return decimal.Zero
+	}
+	if order.OrderType == "sell" {
+		return order.RemainingAmount
+	}
+	if order.HasPrice() {
+		return order.Price.Mul(order.RemainingAmount)
+	}
+	return book.Cost(order, executionLimit(book, order))
+}
+
+// lockFunds brings the funds reserved for an order to what it requires,
+// locking more or releasing the excess. It fails with an *OrderError,
+// changing nothing, if the user cannot cover what is missing. The order
+// itself is not stored.
+func (e *Engine) lockFunds(ctx context.Context, tx pgx.Tx, book *Book, order *models.Order) error {
+	change := requiredFunds(book, order).Sub(order.LockedAmount)
+	if change.IsZero() {
+		return nil
+	}
+
+	entry := e.ledgerEntry(order, models.EntryLock)
+	if change.IsNegative() {
+		entry.EntryType = models.EntryRelease
+	}
+	entry.AvailableChange = change.Neg()
+	entry.LockedChange = change
+
+	err := e.applyEntry(ctx, tx, entry)
+	if errors.Is(err, repository.ErrInsufficientFunds) && change.IsPositive() {
+		return reject(CodeInsufficientFunds, "Insufficient %s balance: %s more is needed", entry.Asset, change)
+	}
+	if err != nil {
+		return err
+	}
+
+	order.LockedAmount = order.LockedAmount.Add(change)
+	return nil
+}
+
+// releaseFunds hands back everything reserved for an order that was taken
+// out of the book without trading, and stores the change
+func (e *Engine) releaseFunds(ctx context.Context, tx pgx.Tx, order *models.Order) error {
+	if order.LockedAmount.IsZero() {
+		return nil
+	}
+
+	entry := e.ledgerEntry(order, models.EntryRelease)
+	entry.AvailableChange = order.LockedAmount
+	entry.LockedChange = order.LockedAmount.Neg()
+	if err := e.applyEntry(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	order.LockedAmount = decimal.Zero
+	return repository.NewOrderRepository(tx).UpdateLocked(ctx, order)
+}
+
+// settleTrades moves the assets of each trade between its buyer and seller.
+// Each side pays out of the funds reserved for its order and is credited
+// what it bought as available funds, less its fee. orders holds the orders
+// of the trades by id.
+func (e *Engine) settleTrades(ctx context.Context, tx pgx.Tx, trades []models.Trade, orders map[int64]*models.Order) error {
+	for i := range trades {
+		trade := &trades[i]
+		notional := trade.Price.Mul(trade.Amount)
+
+		for _, orderID := range []int64{trade.MakerOrderID, trade.TakerOrderID} {
+			order := orders[orderID]
+			market := e.markets[order.Symbol]
+
+			paid, received := notional, trade.Amount
+			paidAsset, receivedAsset := market.QuoteAsset, market.BaseAsset
+			if order.OrderType == "sell" {
+				paid, received = received, paid
+				paidAsset, receivedAsset = receivedAsset, paidAsset
+			}
+			fee := trade.TakerFee
+			if orderID == trade.MakerOrderID {
+				fee = trade.MakerFee
+			}
+
+			entries := []*models.LedgerEntry{
+				{Asset: paidAsset, EntryType: models.EntryTrade, LockedChange: paid.Neg()},
+				{Asset: receivedAsset, EntryType: models.EntryTrade, AvailableChange: received},
+			}
+			if fee.IsPositive() {
+				entries = append(entries, &models.LedgerEntry{Asset: receivedAsset, EntryType: models.EntryFee, AvailableChange: fee.Neg()})
+			}
+			for _, entry := range entries {
+				entry.UserID = order.UserID
+				entry.OrderID = &order.ID
+				entry.TradeID = &trade.ID
+				if err := e.applyEntry(ctx, tx, entry); err != nil {
+					return err
+				}
+			}
+			order.LockedAmount = order.LockedAmount.Sub(paid)
+		}
+	}
+
+	return nil
+}
+
+// balanceKey identifies the balance of one user in one asset
+type balanceKey struct {
+	userID int64
+	asset  string
+}
+
+// applyEntry changes a balance by a ledger entry and keeps the new balance
+// to announce once the operation commits
+func (e *Engine) applyEntry(ctx context.Context, tx pgx.Tx, entry *models.LedgerEntry) error {
+	balance, err := repository.NewBalanceRepository(tx).Apply(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	e.balances[balanceKey{balance.UserID, balance.Asset}] = *balance
+	return nil
+}
+
+// publishBalances tells users about the balances the operation that just
+// committed changed
+func (e *Engine) publishBalances() {
+	for _, balance := range e.balances {
+		e.hub.SendBalanceUpdate(&balance)
+	}
+	clear(e.balances)
+}
+
+// ledgerEntry starts a ledger entry for the funds reserved for an order
+func (e *Engine) ledgerEntry(order *models.Order, entryType string) *models.LedgerEntry {
+	market := e.markets[order.Symbol]
+	asset := market.BaseAsset
+	if order.OrderType == "buy" {
+		asset = market.QuoteAsset
+	}
+
+	return &models.LedgerEntry{
+		UserID:    order.UserID,
+		Asset:     asset,
+		EntryType: entryType,
+		OrderID:   &order.ID,
+	}
+}
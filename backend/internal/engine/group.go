@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"context"
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"crypto-orderbook/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GroupResult is the outcome of placing an order group
+type GroupResult struct {
+	Group  models.OrderGroup `json:"group"`
+	Orders []models.Order    `json:"orders"`
+	Trades []models.Trade    `json:"trades"`
+}
+
+// PlaceGroup stores an OCO or bracket group together with its orders and
+// executes the ones that are live straight away: the limit leg of an OCO
+// group or the entry of a bracket. Stop legs wait for their trigger and
+// bracket legs wait for the entry, all within the same transaction.
+func (e *Engine) PlaceGroup(ctx context.Context, market *models.Market, group *models.OrderGroup, orders []*models.Order) (*GroupResult, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.checkMarket(group.Symbol); err != nil {
+		return nil, err
+	}
+	book := e.book(group.Symbol)
+	lastPrice, traded := e.lastPrices[group.Symbol]
+	for _, order := range orders {
+		if err := e.checkSession(order); err != nil {
+			return nil, err
+		}
+		if err := checkPostOnly(market, book, order); err != nil {
+			return nil, err
+		}
+		if err := startTrailing(order, lastPrice, traded); err != nil {
+			return nil, err
+		}
+		if err := checkTrigger(order, lastPrice, traded); err != nil {
+			return nil, err
+		}
+	}
+
+	var results []*Result
+	groupResult := &GroupResult{}
+	err := repository.WithTx(ctx, e.db, func(tx pgx.Tx) error {
+		orderRepo := repository.NewOrderRepository(tx)
+		if err := repository.NewOrderGroupRepository(tx).Create(ctx, group); err != nil {
+			return err
+		}
+
+		for _, order := range orders {
+			if err := checkReduceOnly(ctx, repository.NewTradeRepository(tx), book, order); err != nil {
+				return err
+			}
+			order.GroupID = &group.ID
+			if err := orderRepo.Create(ctx, order); err != nil {
+				return err
+			}
+		}
+
+		// Every leg has to exist and be paid for before one of them can
+		// trade and cancel the others
+		for _, order := range orders {
+			if order.Status != models.StatusActive {
+				continue
+			}
+			if err := e.lockFunds(ctx, tx, book, order); err != nil {
+				return err
+			}
+		}
+		for _, order := range orders {
+			if order.Status == models.StatusPendingTrigger {
+				pending := *order
+				book.AddStop(&pending)
+			}
+		}
+		for _, order := range orders {
+			if order.Status != models.StatusActive {
+				continue
+			}
+			result, err := e.execute(ctx, tx, book, order)
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+			groupResult.Trades = append(groupResult.Trades, result.Trades...)
+		}
+
+		current, err := orderRepo.GetByGroupID(ctx, group.ID)
+		if err != nil {
+			return err
+		}
+		groupResult.Group = *group
+		groupResult.Orders = current
+
+		return nil
+	})
+	if err != nil {
+		return nil, e.resync(err)
+	}
+
+	for _, result := range results {
+		e.broadcast(result)
+	}
+	e.runTriggers(ctx, group.Symbol)
+
+	return groupResult, nil
+}
+
+// settleGroup applies what happened to an order to the rest of its group. A
+// bracket entry that is done activates its legs for the amount it filled,
+// or cancels them if nothing filled. Any fill on another leg cancels its
+// siblings.
+func (e *Engine) settleGroup(ctx context.Context, tx pgx.Tx, book *Book, order *models.Order, result *Result) error {
+	if order.GroupID == nil {
+		return nil
+	}
+
+	if order.GroupRole == models.RoleEntry {
+		if order.IsOpen() {
+			return nil
+		}
+		if order.FilledAmount.IsPositive() {
+			return e.activateLegs(ctx, tx, book, order, result)
+		}
+		return e.cancelSiblings(ctx, tx, book, order, result)
+	}
+
+	if order.FilledAmount.IsPositive() {
+		return e.cancelSiblings(ctx, tx, book, order, result)
+	}
+	return nil
+}
+
+// cancelSiblings cancels the unfinished orders of the group of order and
+// takes them out of the book. Those that were visible in the book are added
+// to result.Cancelled.
+func (e *Engine) cancelSiblings(ctx context.Context, tx pgx.Tx, book *Book, order *models.Order, result *Result) error {
+	cancelled, err := repository.NewOrderRepository(tx).CancelSiblings(ctx, *order.GroupID, order.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, sibling := range cancelled {
+		if err := e.releaseFunds(ctx, tx, &sibling); err != nil {
+			return err
+		}
+		_, resting := book.orders[sibling.ID]
+		book.Remove(sibling.ID)
+		if resting {
+			result.Cancelled = append(result.Cancelled, sibling)
+		}
+	}
+
+	return nil
+}
+
+// activateLegs makes the take-profit and stop-loss of a filled bracket entry
+// live for the amount the entry filled. A take-profit is executed right away
+// and may trade, or is cancelled if its funds are not there; a stop-loss
+// starts waiting for its trigger.
+func (e *Engine) activateLegs(ctx context.Context, tx pgx.Tx, book *Book, entry *models.Order, result *Result) error {
+	orderRepo := repository.NewOrderRepository(tx)
+	legs, err := orderRepo.ActivateLegs(ctx, *entry.GroupID, entry.FilledAmount)
+	if err != nil {
+		return err
+	}
+
+	// Stop legs go in first so a take-profit that trades straight away can
+	// cancel them. A trailing stop-loss starts trailing from the entry's
+	// fills.
+	for i := range legs {
+		if legs[i].Status != models.StatusPendingTrigger {
+			continue
+		}
+		if legs[i].OrderKind == models.KindTrailingStop {
+			legs[i].TriggerPrice = decimal.Max(trailingTrigger(&legs[i], e.lastPrices[entry.Symbol]), decimal.Zero)
+			if err := orderRepo.UpdateTrigger(ctx, &legs[i]); err != nil {
+				return err
+			}
+		}
+		book.AddStop(&legs[i])
+	}
+	for i := range legs {
+		if legs[i].Status != models.StatusActive {
+			continue
+		}
+		activated, err := e.executeOrCancel(ctx, tx, book, &legs[i])
+		if err != nil {
+			return err
+		}
+		result.Activated = append(result.Activated, activated)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"context"
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"crypto-orderbook/internal/repository"
+	"encoding/json"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// trimReduceOnly brings a user's open reduce-only orders in a market back
+// within what their net position allows after trades moved it. Older orders
+// keep their size first. The legs of one group are alternatives, as only one
+// of them can fill, so each gets what was left when the first was reached.
+// An order with nothing left to reduce is cancelled. Orders that changed are
+// added to result.
+func (e *Engine) trimReduceOnly(ctx context.Context, tx pgx.Tx, book *Book, userID int64, symbol string, result *Result) error {
+	var orders []*models.Order
+	for _, live := range []map[int64]*models.Order{book.orders, book.pending} {
+		for _, order := range live {
+			if order.UserID == userID && order.ReduceOnly {
+				orders = append(orders, order)
+			}
+		}
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+
+	position, err := repository.NewTradeRepository(tx).NetPosition(ctx, userID, symbol)
+	if err != nil {
+		return err
+	}
+
+	// Selling reduces a long position, buying reduces a short one
+	left := map[string]decimal.Decimal{
+		"sell": decimal.Max(position, decimal.Zero),
+		"buy":  decimal.Max(position.Neg(), decimal.Zero),
+	}
+	groups := make(map[int64]decimal.Decimal)
+	for _, order := range orders {
+		available := left[order.OrderType]
+		if order.GroupID != nil {
+			if start, ok := groups[*order.GroupID]; ok {
+				available = start
+			} else {
+				groups[*order.GroupID] = available
+			}
+		}
+
+		kept := decimal.Min(order.RemainingAmount, available)
+		left[order.OrderType] = decimal.Min(left[order.OrderType], available.Sub(kept))
+		if kept.Equal(order.RemainingAmount) {
+			continue
+		}
+		if err := e.reduceOrder(ctx, tx, book, order, kept, result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reduceOrder shrinks an open reduce-only order to keep of its remaining
+// amount, or cancels it if keep is zero, and releases the funds it no longer
+// needs. A resting order that is cancelled goes to result.Cancelled, any
+// other change to result.Reduced.
+func (e *Engine) reduceOrder(ctx context.Context, tx pgx.Tx, book *Book, order *models.Order, keep decimal.Decimal, result *Result) error {
+	oldAmount := order.Amount
+	_, resting := book.orders[order.ID]
+	if keep.IsZero() {
+		order.Status = models.StatusCancelled
+		book.Remove(order.ID)
+	} else {
+		decrement(order, order.RemainingAmount.Sub(keep))
+	}
+
+	if err := e.lockFunds(ctx, tx, book, order); err != nil {
+		return err
+	}
+	if err := repository.NewOrderRepository(tx).UpdateExecution(ctx, order); err != nil {
+		return err
+	}
+	if err := recordReduction(ctx, tx, order, oldAmount); err != nil {
+		return err
+	}
+
+	if resting && order.Status == models.StatusCancelled {
+		result.Cancelled = append(result.Cancelled, *order)
+	} else {
+		result.Reduced = append(result.Reduced, *order)
+	}
+	return nil
+}
+
+// fitReduceOnly shrinks a reduce-only stop that has just triggered to what
+// the user's position still allows next to their other reduce-only orders,
+// since the position may have moved while it waited. The new size is stored
+// as the order executes. It reports false, having cancelled the order, if
+// nothing is left to reduce.
+func fitReduceOnly(ctx context.Context, tx pgx.Tx, book *Book, order *models.Order) (bool, error) {
+	if !order.ReduceOnly {
+		return true, nil
+	}
+
+	reducible, err := reducibleAmount(ctx, repository.NewTradeRepository(tx), book, order)
+	if err != nil {
+		return false, err
+	}
+	if order.RemainingAmount.LessThanOrEqual(reducible) {
+		return true, nil
+	}
+
+	oldAmount := order.Amount
+	if reducible.IsZero() {
+		order.Status = models.StatusCancelled
+		if err := repository.NewOrderRepository(tx).UpdateExecution(ctx, order); err != nil {
+			return false, err
+		}
+	} else {
+		decrement(order, order.RemainingAmount.Sub(reducible))
+	}
+	if err := recordReduction(ctx, tx, order, oldAmount); err != nil {
+		return false, err
+	}
+
+	return order.Status != models.StatusCancelled, nil
+}
+
+// recordReduction adds a "reduced" event to the history of an order that
+// was shrunk from oldAmount or cancelled to stay reduce-only
+func recordReduction(ctx context.Context, tx pgx.Tx, order *models.Order, oldAmount decimal.Decimal) error {
+	newAmount := order.Amount
+	if order.Status == models.StatusCancelled {
+		newAmount = order.FilledAmount
+	}
+	data, err := json.Marshal(models.Amendment{
+		OldPrice:     order.Price,
+		NewPrice:     order.Price,
+		OldAmount:    oldAmount,
+		NewAmount:    newAmount,
+		PriorityKept: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	return repository.NewOrderEventRepository(tx).Create(ctx, &models.OrderEvent{
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		EventType: models.EventReduced,
+		Data:      data,
+	})
+}
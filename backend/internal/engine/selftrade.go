@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"context"
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"crypto-orderbook/internal/repository"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// selfTrade reports whether taker would trade against its own user's resting
+// order and has to apply its self-trade prevention mode instead
+func selfTrade(taker, maker *models.Order) bool {
+	return maker.UserID == taker.UserID && taker.STPMode != models.STPNone
+}
+
+// preventSelfTrade applies the taker's self-trade prevention mode to the
+// maker at the front of lvl instead of trading. A cancelled maker leaves the
+// book; a cancelled taker stops matching. It reports whether the maker
+// changed.
+func (b *Book) preventSelfTrade(taker, maker *models.Order, lvl *level) bool {
+	prevention := models.SelfTradePrevention{
+		Symbol:       taker.Symbol,
+		UserID:       taker.UserID,
+		Mode:         taker.STPMode,
+		TakerOrderID: taker.ID,
+		MakerOrderID: maker.ID,
+		Price:        lvl.price,
+		Amount:       decimal.Min(taker.RemainingAmount, maker.Shown()),
+		Cancelled:    []int64{},
+	}
+
+	cancelTaker, cancelMaker := false, false
+	switch taker.STPMode {
+	case models.STPCancelOldest:
+		cancelMaker = true
+	case models.STPCancelBoth:
+		cancelTaker, cancelMaker = true, true
+	case models.STPDecrementCancel:
+		// The smaller order is cancelled and the larger one shrinks by its
+		// size; orders of equal size are both cancelled
+		qty := decimal.Min(taker.RemainingAmount, maker.RemainingAmount)
+		prevention.Amount = qty
+		cancelTaker = taker.RemainingAmount.Equal(qty)
+		cancelMaker = maker.RemainingAmount.Equal(qty)
+		if !cancelTaker {
+			decrement(taker, qty)
+		}
+		if !cancelMaker {
+			decrement(maker, qty)
+		}
+	default:
+		cancelTaker = true
+	}
+
+	if cancelTaker {
+		taker.Status = models.StatusCancelled
+		prevention.Cancelled = append(prevention.Cancelled, taker.ID)
+	}
+	if cancelMaker {
+		maker.Status = models.StatusCancelled
+		prevention.Cancelled = append(prevention.Cancelled, maker.ID)
+		lvl.orders = lvl.orders[1:]
+		delete(b.orders, maker.ID)
+	}
+
+	b.prevented = append(b.prevented, prevention)
+	return cancelMaker || taker.STPMode == models.STPDecrementCancel
+}
+
+// TakePrevented returns the self-trades avoided since the last call and
+// forgets them
+func (b *Book) TakePrevented() []models.SelfTradePrevention {
+	prevented := b.prevented
+	b.prevented = nil
+	return prevented
+}
+
+// decrement shrinks an order by qty that will never trade
+func decrement(order *models.Order, qty decimal.Decimal) {
+	order.Amount = order.Amount.Sub(qty)
+	order.RemainingAmount = order.RemainingAmount.Sub(qty)
+	if order.IsIceberg() {
+		order.VisibleAmount = decimal.Min(order.VisibleAmount, order.RemainingAmount)
+	}
+}
+
+// recordPreventions adds the avoided self-trades to the history of both
+// orders involved and to result. A group leg cancelled this way takes the
+// rest of its group with it, as if the user had cancelled it.
+func (e *Engine) recordPreventions(ctx context.Context, tx pgx.Tx, book *Book, orders map[int64]*models.Order, prevented []models.SelfTradePrevention, result *Result) error {
+	eventRepo := repository.NewOrderEventRepository(tx)
+
+	for _, prevention := range prevented {
+		data, err := json.Marshal(prevention)
+		if err != nil {
+			return err
+		}
+		for _, orderID := range []int64{prevention.TakerOrderID, prevention.MakerOrderID} {
+			err := eventRepo.Create(ctx, &models.OrderEvent{
+				OrderID:   orderID,
+				UserID:    prevention.UserID,
+				EventType: models.EventSelfTradePrevented,
+				Data:      data,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, orderID := range prevention.Cancelled {
+			order := orders[orderID]
+			if order.GroupID == nil || order.GroupRole == models.RoleEntry || order.FilledAmount.IsPositive() {
+				// Entries and legs that traded are settled like any other
+				continue
+			}
+			if err := e.cancelSiblings(ctx, tx, book, order, result); err != nil {
+				return err
+			}
+		}
+
+		result.Prevented = append(result.Prevented, prevention)
+	}
+
+	return nil
+}
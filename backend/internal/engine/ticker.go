@@ -0,0 +1,26 @@
+package engine
+
+import (
+	"crypto-orderbook/internal/models"
+	"time"
+)
+
+// publishTicker announces the last price and best bid and ask of a market
+// if they have changed since they were last announced
+func (e *Engine) publishTicker(symbol string) {
+	book := e.book(symbol)
+	ticker := models.Ticker{Symbol: symbol, LastPrice: e.lastPrices[symbol]}
+	ticker.BestBid, _ = book.BestPrice("buy")
+	ticker.BestAsk, _ = book.BestPrice("sell")
+
+	if last, ok := e.tickers[symbol]; ok &&
+		last.LastPrice.Equal(ticker.LastPrice) &&
+		last.BestBid.Equal(ticker.BestBid) &&
+		last.BestAsk.Equal(ticker.BestAsk) {
+		return
+	}
+
+	ticker.Time = time.Now()
+	e.tickers[symbol] = ticker
+	e.hub.BroadcastTicker(&ticker)
+}
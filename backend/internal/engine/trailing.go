@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"sort"
+)
+
+// CodeNoReferencePrice rejects a trailing stop in a market that has not
+// traded yet, since there is no price to trail
+const CodeNoReferencePrice = "NO_REFERENCE_PRICE"
+
+// startTrailing places the first trigger of a trailing stop its offset away
+// from the last trade price
+func startTrailing(order *models.Order, lastPrice decimal.Decimal, traded bool) error {
+	if order.OrderKind != models.KindTrailingStop || order.Status != models.StatusPendingTrigger {
+		return nil
+	}
+	if !traded {
+		return reject(CodeNoReferencePrice, "Trailing stops need a last trade price; %s has not traded yet", order.Symbol)
+	}
+
+	order.TriggerPrice = trailingTrigger(order, lastPrice)
+	if !order.TriggerPrice.IsPositive() {
+		return reject(CodeNoReferencePrice, "Trail offset is larger than the last price %s", lastPrice)
+	}
+
+	return nil
+}
+
+// trailingTrigger is where the trigger of a trailing stop sits for a price:
+// below it for a sell, above it for a buy
+func trailingTrigger(order *models.Order, price decimal.Decimal) decimal.Decimal {
+	offset := order.TrailOffset
+	if order.TrailType == models.TrailPercentage {
+		offset = price.Mul(order.TrailOffset)
+	}
+
+	if order.OrderType == "buy" {
+		return price.Add(offset)
+	}
+	return price.Sub(offset)
+}
+
+// Trail ratchets the triggers of the trailing stops waiting in the book
+// towards each trade price in turn. A sell's trigger only ever rises and a
+// buy's only ever falls. It returns the orders whose trigger moved, by id.
+func (b *Book) Trail(trades []models.Trade) []*models.Order {
+	moved := make(map[int64]*models.Order)
+	for _, trade := range trades {
+		for _, order := range b.pending {
+			if order.OrderKind != models.KindTrailingStop {
+				continue
+			}
+			trigger := trailingTrigger(order, trade.Price)
+			if (order.OrderType == "sell" && trigger.GreaterThan(order.TriggerPrice)) ||
+				(order.OrderType == "buy" && trigger.LessThan(order.TriggerPrice)) {
+				order.TriggerPrice = trigger
+				moved[order.ID] = order
+			}
+		}
+	}
+
+	orders := make([]*models.Order, 0, len(moved))
+	for _, order := range moved {
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+	return orders
+}
@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"crypto-orderbook/internal/models"
+	"fmt"
+)
+
+// Rejection codes returned to clients alongside the error message
+const (
+	CodePriceTickSize    = "PRICE_TICK_SIZE"
+	CodeQuantityStepSize = "QUANTITY_STEP_SIZE"
+	CodeQuantityTooSmall = "QUANTITY_BELOW_MIN"
+	CodeNotionalTooSmall = "NOTIONAL_BELOW_MIN"
+	CodeNotionalTooLarge = "NOTIONAL_ABOVE_MAX"
+)
+
+// OrderError is an order rejected by a trading rule. Code is stable and meant
+// for programs; Message is meant for people.
+type OrderError struct {
+	Code    string `json:"code"`
+	Message string `json:"error"`
+}
+
+func (e *OrderError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func reject(code, format string, args ...any) *OrderError {
+	return &OrderError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ValidateOrder checks an order against the price tick, quantity step,
+// minimum quantity and notional limits of its market. An iceberg's display
+// amount follows the same quantity rules as its amount. Market and stop orders
+// have no price, so only their quantity and trigger are checked.
+func ValidateOrder(market *models.Market, order *models.Order) error {
+	if order.IsStop() && !order.TriggerPrice.IsMultipleOf(market.TickSize) {
+		return reject(CodePriceTickSize, "Trigger price must be a multiple of %s", market.TickSize)
+	}
+
+	if order.TrailType == models.TrailAbsolute && !order.TrailOffset.IsMultipleOf(market.TickSize) {
+		return reject(CodePriceTickSize, "Trail offset must be a multiple of %s", market.TickSize)
+	}
+
+	if !order.Amount.IsMultipleOf(market.LotSize) {
+		return reject(CodeQuantityStepSize, "Amount must be a multiple of %s", market.LotSize)
+	}
+
+	if order.Amount.LessThan(market.MinQuantity) {
+		return reject(CodeQuantityTooSmall, "Amount must be at least %s", market.MinQuantity)
+	}
+
+	if order.IsIceberg() {
+		if !order.DisplayAmount.IsMultipleOf(market.LotSize) {
+			return reject(CodeQuantityStepSize, "Display amount must be a multiple of %s", market.LotSize)
+		}
+		if order.DisplayAmount.LessThan(market.MinQuantity) {
+			return reject(CodeQuantityTooSmall, "Display amount must be at least %s", market.MinQuantity)
+		}
+	}
+
+	if !order.HasPrice() {
+		return nil
+	}
+
+	if !order.Price.IsMultipleOf(market.TickSize) {
+		return reject(CodePriceTickSize, "Price must be a multiple of %s", market.TickSize)
+	}
+
+	notional := order.Price.Mul(order.Amount)
+	if notional.LessThan(market.MinNotional) {
+		return reject(CodeNotionalTooSmall, "Order value must be at least %s %s", market.MinNotional, market.QuoteAsset)
+	}
+
+	if market.MaxNotional.IsPositive() && notional.GreaterThan(market.MaxNotional) {
+		return reject(CodeNotionalTooLarge, "Order value must be at most %s %s", market.MaxNotional, market.QuoteAsset)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+// Package fees keeps users' fee tiers in line with their trading volume
+// and reports the fee schedule that applies to them.
+package fees
+
+import (
+	"context"
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"crypto-orderbook/internal/repository"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Service recomputes fee tiers from the last 30 days of trades, with
+// volume valued in volumeAsset
+type Service struct {
+	db          *pgxpool.Pool
+	volumeAsset string
+}
+
+func NewService(db *pgxpool.Pool, volumeAsset string) *Service {
+	return &Service{db: db, volumeAsset: volumeAsset}
+}
+
+// RunTiers recomputes every user's fee tier straight away and then every
+// interval until ctx is done
+func (s *Service) RunTiers(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RecomputeTiers(ctx); err != nil {
+			log.Printf("Error recomputing fee tiers: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RecomputeTiers sets the 30-day volume and fee tier of every user
+func (s *Service) RecomputeTiers(ctx context.Context) error {
+	updated, err := repository.NewFeeRepository(s.db).RecomputeTiers(ctx, s.volumeAsset)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Fee tiers recomputed for %d users", updated)
+	return nil
+}
+
+// Schedule returns a user's fee tier, the rates it gives in every market
+// and the volume still needed for the next tier
+func (s *Service) Schedule(ctx context.Context, userID int64) (*models.FeeSchedule, error) {
+	feeRepo := repository.NewFeeRepository(s.db)
+
+	schedule, err := feeRepo.GetUserVolume(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	schedule.VolumeAsset = s.volumeAsset
+
+	tiers, err := feeRepo.GetTiers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tiers {
+		if tiers[i].MinVolume.GreaterThan(schedule.Tier.MinVolume) {
+			schedule.NextTier = &tiers[i]
+			// Tiers are only recomputed periodically, so the volume can
+			// already be past the next tier's minimum
+			schedule.VolumeToNextTier = decimal.Max(tiers[i].MinVolume.Sub(schedule.Volume30d), decimal.Zero)
+			break
+		}
+	}
+
+	markets, err := repository.NewMarketRepository(s.db).GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	schedule.Markets = make([]models.MarketFees, len(markets))
+	for i := range markets {
+		schedule.Markets[i] = models.MarketFees{
+			Symbol:    markets[i].Symbol,
+			MakerRate: schedule.Tier.MakerRate(&markets[i]),
+			TakerRate: schedule.Tier.TakerRate(&markets[i]),
+		}
+	}
+
+	return schedule, nil
+}
@@ -0,0 +1,211 @@
+// Package funding simulates moving assets in and out of the exchange for
+// testnet use: deposits that are credited after a number of confirmations
+// and withdrawals that wait for an admin.
+package funding
+
+import (
+	"context"
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"crypto-orderbook/internal/repository"
+	"crypto-orderbook/internal/websocket"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Service applies deposits and withdrawals to balances. Every change of a
+// balance is written to the ledger in the same transaction as the state
+// change that caused it, and pushed to its user once that commits.
+type Service struct {
+	db            *pgxpool.Pool
+	hub           *websocket.Hub
+	confirmations int
+}
+
+func NewService(db *pgxpool.Pool, hub *websocket.Hub, confirmations int) *Service {
+	return &Service{db: db, hub: hub, confirmations: confirmations}
+}
+
+// Deposit records a deposit. Without confirmations to wait for it is
+// credited straight away; otherwise RunConfirmations credits it later.
+func (s *Service) Deposit(ctx context.Context, deposit *models.Deposit) error {
+	deposit.RequiredConfirmations = s.confirmations
+	deposit.Status = models.DepositPending
+	if s.confirmations == 0 {
+		deposit.Status = models.DepositCompleted
+	}
+
+	var balances []*models.Balance
+	err := repository.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		if err := repository.NewDepositRepository(tx).Create(ctx, deposit); err != nil {
+			return err
+		}
+		if deposit.Status != models.DepositCompleted {
+			return nil
+		}
+		return credit(ctx, tx, deposit, &balances)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publish(balances)
+	return nil
+}
+
+// RunConfirmations adds a simulated confirmation to the pending deposits
+// every interval until ctx is done
+func (s *Service) RunConfirmations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ConfirmDeposits(ctx); err != nil {
+				log.Printf("Error confirming deposits: %v", err)
+			}
+		}
+	}
+}
+
+// ConfirmDeposits adds a confirmation to every pending deposit and credits
+// the ones that have enough
+func (s *Service) ConfirmDeposits(ctx context.Context) error {
+	var balances []*models.Balance
+	err := repository.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		completed, err := repository.NewDepositRepository(tx).Confirm(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := range completed {
+			if err := credit(ctx, tx, &completed[i], &balances); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publish(balances)
+	return nil
+}
+
+// RequestWithdrawal records a pending withdrawal and locks its funds. It
+// returns repository.ErrInsufficientFunds if they are not available.
+func (s *Service) RequestWithdrawal(ctx context.Context, withdrawal *models.Withdrawal) error {
+	var balances []*models.Balance
+	err := repository.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		if err := repository.NewWithdrawalRepository(tx).Create(ctx, withdrawal); err != nil {
+			return err
+		}
+		return move(ctx, tx, withdrawal, withdrawal.Amount.Neg(), withdrawal.Amount, &balances)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publish(balances)
+	return nil
+}
+
+// ApproveWithdrawal clears a pending withdrawal to be sent. Its funds stay
+// locked, so the approval goes in the ledger as an entry that moves nothing.
+func (s *Service) ApproveWithdrawal(ctx context.Context, id int64) (*models.Withdrawal, error) {
+	return s.transition(ctx, id, []string{models.WithdrawalPending}, models.WithdrawalApproved)
+}
+
+// RejectWithdrawal refuses a pending or approved withdrawal and makes its
+// funds available again
+func (s *Service) RejectWithdrawal(ctx context.Context, id int64) (*models.Withdrawal, error) {
+	return s.transition(ctx, id, []string{models.WithdrawalPending, models.WithdrawalApproved}, models.WithdrawalRejected)
+}
+
+// CompleteWithdrawal marks an approved withdrawal as sent, taking its funds
+// out of the locked balance
+func (s *Service) CompleteWithdrawal(ctx context.Context, id int64) (*models.Withdrawal, error) {
+	return s.transition(ctx, id, []string{models.WithdrawalApproved}, models.WithdrawalCompleted)
+}
+
+// transition moves a withdrawal from one of from to status together with the
+// balance change that goes with it
+func (s *Service) transition(ctx context.Context, id int64, from []string, status string) (*models.Withdrawal, error) {
+	var withdrawal *models.Withdrawal
+	var balances []*models.Balance
+	err := repository.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		var err error
+		withdrawal, err = repository.NewWithdrawalRepository(tx).UpdateStatus(ctx, id, from, status)
+		if err != nil {
+			return err
+		}
+
+		switch status {
+		case models.WithdrawalApproved:
+			return move(ctx, tx, withdrawal, decimal.Zero, decimal.Zero, &balances)
+		case models.WithdrawalRejected:
+			return move(ctx, tx, withdrawal, withdrawal.Amount, withdrawal.Amount.Neg(), &balances)
+		case models.WithdrawalCompleted:
+			return move(ctx, tx, withdrawal, decimal.Zero, withdrawal.Amount.Neg(), &balances)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(balances)
+	return withdrawal, nil
+}
+
+// publish tells users about their balances changed by a committed
+// transaction
+func (s *Service) publish(balances []*models.Balance) {
+	for _, balance := range balances {
+		s.hub.SendBalanceUpdate(balance)
+	}
+}
+
+// credit adds a completed deposit to the available balance and collects the
+// new balance in balances
+func credit(ctx context.Context, tx pgx.Tx, deposit *models.Deposit, balances *[]*models.Balance) error {
+	return apply(ctx, tx, &models.LedgerEntry{
+		UserID:          deposit.UserID,
+		Asset:           deposit.Asset,
+		EntryType:       models.EntryDeposit,
+		AvailableChange: deposit.Amount,
+		DepositID:       &deposit.ID,
+	}, balances)
+}
+
+// move changes the balance a withdrawal is paid from and collects the new
+// balance in balances
+func move(ctx context.Context, tx pgx.Tx, withdrawal *models.Withdrawal, available, locked decimal.Decimal, balances *[]*models.Balance) error {
+	return apply(ctx, tx, &models.LedgerEntry{
+		UserID:          withdrawal.UserID,
+		Asset:           withdrawal.Asset,
+		EntryType:       models.EntryWithdrawal,
+		AvailableChange: available,
+		LockedChange:    locked,
+		WithdrawalID:    &withdrawal.ID,
+	}, balances)
+}
+
+// apply changes a balance by a ledger entry and collects the new balance in
+// balances
+func apply(ctx context.Context, tx pgx.Tx, entry *models.LedgerEntry, balances *[]*models.Balance) error {
+	balance, err := repository.NewBalanceRepository(tx).Apply(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	*balances = append(*balances, balance)
+	return nil
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"crypto-orderbook/internal/fees"
+	"crypto-orderbook/internal/models"
+	"crypto-orderbook/internal/repository"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AccountHandler struct {
+	userRepo *repository.UserRepository
+	fees     *fees.Service
+}
+
+func NewAccountHandler(userRepo *repository.UserRepository, feeService *fees.Service) *AccountHandler {
+	return &AccountHandler{userRepo: userRepo, fees: feeService}
+}
+
+// GetAccount returns the user together with their account settings
+func (h *AccountHandler) GetAccount(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	user, err := h.userRepo.GetByID(c.Context(), userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get account"})
+	}
+
+	return c.JSON(user)
+}
+
+// UpdateAccount changes the user's account settings, currently the default
+// self-trade prevention mode
+func (h *AccountHandler) UpdateAccount(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	var req models.UpdateAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	req.DefaultSTPMode = strings.ToLower(strings.TrimSpace(req.DefaultSTPMode))
+	if !models.ValidSTPMode(req.DefaultSTPMode) {
+		return c.Status(400).JSON(fiber.Map{"error": "Self-trade prevention mode must be 'none', 'cancel_newest', 'cancel_oldest', 'cancel_both' or 'decrement_cancel'"})
+	}
+
+	user, err := h.userRepo.UpdateDefaultSTPMode(c.Context(), userID, req.DefaultSTPMode)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update account"})
+	}
+
+	return c.JSON(user)
+}
+
+// GetFees returns the user's fee tier, their maker and taker rates in every
+// market and the 30-day volume needed for the next tier
+func (h *AccountHandler) GetFees(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	schedule, err := h.fees.Schedule(c.Context(), userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get fees"})
+	}
+
+	return c.JSON(schedule)
+}
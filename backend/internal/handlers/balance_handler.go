@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"crypto-orderbook/internal/repository"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultLedgerLimit = 100
+	maxLedgerLimit     = 1000
+)
+
+type BalanceHandler struct {
+	balanceRepo *repository.BalanceRepository
+}
+
+func NewBalanceHandler(balanceRepo *repository.BalanceRepository) *BalanceHandler {
+	return &BalanceHandler{balanceRepo: balanceRepo}
+}
+
+// GetBalances returns the user's available and locked funds per asset
+func (h *BalanceHandler) GetBalances(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	balances, err := h.balanceRepo.GetByUserID(c.Context(), userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get balances"})
+	}
+
+	return c.JSON(balances)
+}
+
+// GetLedger returns the user's balance movements, newest first, optionally
+// for one ?asset only
+func (h *BalanceHandler) GetLedger(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	limit := c.QueryInt("limit", defaultLedgerLimit)
+	if limit <= 0 || limit > maxLedgerLimit {
+		return c.Status(400).JSON(fiber.Map{"error": "Limit must be between 1 and 1000"})
+	}
+
+	asset := strings.ToUpper(strings.TrimSpace(c.Query("asset")))
+	entries, err := h.balanceRepo.GetLedger(c.Context(), userID, asset, limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get ledger"})
+	}
+
+	return c.JSON(entries)
+}
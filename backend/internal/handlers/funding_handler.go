@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/engine"
+	"crypto-orderbook/internal/funding"
+	"crypto-orderbook/internal/models"
+	"crypto-orderbook/internal/repository"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxFundingAmount is the largest value a DECIMAL(28,8) balance can hold
+var maxFundingAmount = decimal.MustParse("99999999999999999999.99999999")
+
+type FundingHandler struct {
+	funding        *funding.Service
+	depositRepo    *repository.DepositRepository
+	withdrawalRepo *repository.WithdrawalRepository
+	marketRepo     *repository.MarketRepository
+}
+
+func NewFundingHandler(fundingService *funding.Service, depositRepo *repository.DepositRepository, withdrawalRepo *repository.WithdrawalRepository, marketRepo *repository.MarketRepository) *FundingHandler {
+	return &FundingHandler{
+		funding:        fundingService,
+		depositRepo:    depositRepo,
+		withdrawalRepo: withdrawalRepo,
+		marketRepo:     marketRepo,
+	}
+}
+
+// CreateDeposit simulates a deposit of an asset into the user's balance
+func (h *FundingHandler) CreateDeposit(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	var req models.CreateDepositRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	asset, err := h.checkFunding(c, req.Asset, req.Amount)
+	if err != nil {
+		return fundingErrorResponse(c, err, "Failed to create deposit")
+	}
+
+	deposit := &models.Deposit{UserID: userID, Asset: asset, Amount: req.Amount}
+	if err := h.funding.Deposit(c.Context(), deposit); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create deposit"})
+	}
+
+	return c.Status(201).JSON(deposit)
+}
+
+// GetMyDeposits returns the user's deposits, newest first
+func (h *FundingHandler) GetMyDeposits(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	deposits, err := h.depositRepo.GetByUserID(c.Context(), userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get deposits"})
+	}
+
+	return c.JSON(deposits)
+}
+
+// CreateWithdrawal requests a withdrawal, locking its funds until an admin
+// completes or rejects it
+func (h *FundingHandler) CreateWithdrawal(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	var req models.CreateWithdrawalRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	asset, err := h.checkFunding(c, req.Asset, req.Amount)
+	if err != nil {
+		return fundingErrorResponse(c, err, "Failed to create withdrawal")
+	}
+
+	req.Address = strings.TrimSpace(req.Address)
+	if req.Address == "" || len(req.Address) > 255 {
+		return c.Status(400).JSON(fiber.Map{"error": "Address is required and must be at most 255 characters"})
+	}
+
+	withdrawal := &models.Withdrawal{UserID: userID, Asset: asset, Amount: req.Amount, Address: req.Address}
+	if err := h.funding.RequestWithdrawal(c.Context(), withdrawal); err != nil {
+		if errors.Is(err, repository.ErrInsufficientFunds) {
+			return c.Status(400).JSON(engine.OrderError{
+				Code:    engine.CodeInsufficientFunds,
+				Message: "Insufficient " + asset + " balance",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create withdrawal"})
+	}
+
+	return c.Status(201).JSON(withdrawal)
+}
+
+// GetMyWithdrawals returns the user's withdrawals, newest first
+func (h *FundingHandler) GetMyWithdrawals(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	withdrawals, err := h.withdrawalRepo.GetByUserID(c.Context(), userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get withdrawals"})
+	}
+
+	return c.JSON(withdrawals)
+}
+
+// GetWithdrawals lists the withdrawals of every user for admins, optionally
+// only those in one ?status
+func (h *FundingHandler) GetWithdrawals(c *fiber.Ctx) error {
+	status := strings.ToLower(strings.TrimSpace(c.Query("status")))
+	switch status {
+	case "", models.WithdrawalPending, models.WithdrawalApproved, models.WithdrawalCompleted, models.WithdrawalRejected:
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "Status must be 'pending', 'approved', 'completed' or 'rejected'"})
+	}
+
+	withdrawals, err := h.withdrawalRepo.GetByStatus(c.Context(), status)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get withdrawals"})
+	}
+
+	return c.JSON(withdrawals)
+}
+
+// ApproveWithdrawal moves a pending withdrawal to approved
+func (h *FundingHandler) ApproveWithdrawal(c *fiber.Ctx) error {
+	return h.reviewWithdrawal(c, h.funding.ApproveWithdrawal)
+}
+
+// RejectWithdrawal moves a pending or approved withdrawal to rejected and
+// releases its funds
+func (h *FundingHandler) RejectWithdrawal(c *fiber.Ctx) error {
+	return h.reviewWithdrawal(c, h.funding.RejectWithdrawal)
+}
+
+// CompleteWithdrawal moves an approved withdrawal to completed and takes its
+// funds out of the user's balance
+func (h *FundingHandler) CompleteWithdrawal(c *fiber.Ctx) error {
+	return h.reviewWithdrawal(c, h.funding.CompleteWithdrawal)
+}
+
+func (h *FundingHandler) reviewWithdrawal(c *fiber.Ctx, action func(ctx context.Context, id int64) (*models.Withdrawal, error)) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid withdrawal id"})
+	}
+
+	withdrawal, err := action(c.Context(), int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrWithdrawalNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": "Withdrawal not found"})
+		case errors.Is(err, repository.ErrWithdrawalStatus):
+			return c.Status(409).JSON(fiber.Map{"error": "Withdrawal cannot move to that status from its current one"})
+		default:
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to update withdrawal"})
+		}
+	}
+
+	return c.JSON(withdrawal)
+}
+
+// checkFunding validates the asset and amount of a deposit or withdrawal and
+// returns the asset in its canonical form
+func (h *FundingHandler) checkFunding(c *fiber.Ctx, asset string, amount decimal.Decimal) (string, error) {
+	asset = strings.ToUpper(strings.TrimSpace(asset))
+	if asset == "" {
+		return "", fiber.NewError(400, "Asset is required")
+	}
+	if !amount.IsPositive() || amount.GreaterThan(maxFundingAmount) {
+		return "", fiber.NewError(400, "Amount must be greater than 0 and at most "+maxFundingAmount.String())
+	}
+
+	exists, err := h.marketRepo.AssetExists(c.Context(), asset)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fiber.NewError(400, "Unknown asset")
+	}
+
+	return asset, nil
+}
+
+func fundingErrorResponse(c *fiber.Ctx, err error, message string) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": message})
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"crypto-orderbook/internal/repository"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type MarketHandler struct {
+	marketRepo *repository.MarketRepository
+}
+
+func NewMarketHandler(marketRepo *repository.MarketRepository) *MarketHandler {
+	return &MarketHandler{marketRepo: marketRepo}
+}
+
+func (h *MarketHandler) GetMarkets(c *fiber.Ctx) error {
+	markets, err := h.marketRepo.GetAll(c.Context())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get markets"})
+	}
+
+	return c.JSON(markets)
+}
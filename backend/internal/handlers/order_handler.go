@@ -1,27 +1,50 @@
 package handlers
 
 import (
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/engine"
 	"crypto-orderbook/internal/models"
 	"crypto-orderbook/internal/repository"
-	"crypto-orderbook/internal/websocket"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// maxOrderValue is the largest value a DECIMAL(18,8) column can hold
+var maxOrderValue = decimal.MustParse("9999999999.99999999")
+
 type OrderHandler struct {
-	orderRepo *repository.OrderRepository
-	hub       *websocket.Hub
+	orderRepo      *repository.OrderRepository
+	orderEventRepo *repository.OrderEventRepository
+	marketRepo     *repository.MarketRepository
+	engine         *engine.Engine
 }
 
-func NewOrderHandler(orderRepo *repository.OrderRepository, hub *websocket.Hub) *OrderHandler {
+func NewOrderHandler(orderRepo *repository.OrderRepository, orderEventRepo *repository.OrderEventRepository, marketRepo *repository.MarketRepository, eng *engine.Engine) *OrderHandler {
 	return &OrderHandler{
-		orderRepo: orderRepo,
-		hub:       hub,
+		orderRepo:      orderRepo,
+		orderEventRepo: orderEventRepo,
+		marketRepo:     marketRepo,
+		engine:         eng,
 	}
 }
 
 func (h *OrderHandler) GetOrderBook(c *fiber.Ctx) error {
-	orderBook, err := h.orderRepo.GetOrderBook(c.Context())
+	symbol := strings.ToUpper(strings.TrimSpace(c.Query("symbol")))
+	if symbol == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Symbol is required"})
+	}
+
+	if _, err := h.marketRepo.GetBySymbol(c.Context(), symbol); err != nil {
+		if errors.Is(err, repository.ErrMarketNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Market not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get order book"})
+	}
+
+	orderBook, err := h.orderRepo.GetOrderBook(c.Context(), symbol)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to get order book"})
 	}
@@ -30,39 +53,315 @@ func (h *OrderHandler) GetOrderBook(c *fiber.Ctx) error {
 }
 
 func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(int64)
-	username := c.Locals("username").(string)
-
 	var req models.CreateOrderRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
+	market, order, err := h.newOrder(c, &req)
+	if err != nil {
+		return orderErrorResponse(c, err, "Failed to create order")
+	}
+
+	// Match against the book; the engine persists and broadcasts the outcome
+	result, err := h.engine.PlaceOrder(c.Context(), market, order)
+	if err != nil {
+		return orderErrorResponse(c, err, "Failed to create order")
+	}
+
+	response := models.OrderResponse{Order: result.Order, Fills: models.UserFills(result.Trades, order.UserID)}
+
+	return c.Status(201).JSON(response)
+}
+
+// newOrder checks an order request and builds the order it describes.
+// Malformed requests fail with a *fiber.Error, orders breaking a trading
+// rule of their market with an *engine.OrderError.
+func (h *OrderHandler) newOrder(c *fiber.Ctx, req *models.CreateOrderRequest) (*models.Market, *models.Order, error) {
+	userID := c.Locals("userID").(int64)
+	username := c.Locals("username").(string)
+
+	req.Symbol = strings.ToUpper(strings.TrimSpace(req.Symbol))
+	if req.Symbol == "" {
+		return nil, nil, fiber.NewError(400, "Symbol is required")
+	}
+
+	market, err := h.marketRepo.GetBySymbol(c.Context(), req.Symbol)
+	if err != nil {
+		if errors.Is(err, repository.ErrMarketNotFound) {
+			return nil, nil, fiber.NewError(400, "Unknown market")
+		}
+		return nil, nil, fiber.NewError(500, "Failed to create order")
+	}
+	if market.Status != models.MarketActive {
+		return nil, nil, fiber.NewError(400, "Market is not open for trading")
+	}
+
 	if req.OrderType != "buy" && req.OrderType != "sell" {
-		return c.Status(400).JSON(fiber.Map{"error": "Order type must be 'buy' or 'sell'"})
+		return nil, nil, fiber.NewError(400, "Order type must be 'buy' or 'sell'")
 	}
 
-	if req.Price <= 0 || req.Amount <= 0 {
-		return c.Status(400).JSON(fiber.Map{"error": "Price and amount must be greater than 0"})
+	if req.OrderKind == "" {
+		req.OrderKind = models.KindLimit
+	}
+
+	switch req.OrderKind {
+	case models.KindLimit:
+		if !req.Price.IsPositive() || !req.Amount.IsPositive() {
+			return nil, nil, fiber.NewError(400, "Price and amount must be greater than 0")
+		}
+	case models.KindStopLimit:
+		if !req.Price.IsPositive() || !req.Amount.IsPositive() {
+			return nil, nil, fiber.NewError(400, "Price and amount must be greater than 0")
+		}
+	case models.KindStop, models.KindTrailingStop:
+		if !req.Price.IsZero() {
+			return nil, nil, fiber.NewError(400, "Stop orders cannot have a price; use a stop-limit order")
+		}
+		if !req.Amount.IsPositive() {
+			return nil, nil, fiber.NewError(400, "Amount must be greater than 0")
+		}
+	case models.KindMarket:
+		if !req.Price.IsZero() {
+			return nil, nil, fiber.NewError(400, "Market orders cannot have a price")
+		}
+		if !req.Amount.IsPositive() {
+			return nil, nil, fiber.NewError(400, "Amount must be greater than 0")
+		}
+		if req.WorstPrice.IsNegative() || req.WorstPrice.GreaterThan(maxOrderValue) {
+			return nil, nil, fiber.NewError(400, "Invalid worst price")
+		}
+		if req.MaxSlippage.IsNegative() || req.MaxSlippage.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+			return nil, nil, fiber.NewError(400, "Max slippage must be between 0 and 1")
+		}
+	default:
+		return nil, nil, fiber.NewError(400, "Order kind must be 'limit', 'market', 'stop', 'stop_limit' or 'trailing_stop'")
+	}
+
+	if req.OrderKind != models.KindMarket && (!req.WorstPrice.IsZero() || !req.MaxSlippage.IsZero()) {
+		return nil, nil, fiber.NewError(400, "Worst price and max slippage only apply to market orders")
+	}
+
+	isStop := req.OrderKind == models.KindStop || req.OrderKind == models.KindStopLimit
+	if isStop && !req.TriggerPrice.IsPositive() {
+		return nil, nil, fiber.NewError(400, "Stop orders need a trigger price greater than 0")
+	}
+	if !isStop && !req.TriggerPrice.IsZero() {
+		return nil, nil, fiber.NewError(400, "Only stop and stop-limit orders can have a trigger price")
+	}
+
+	if req.OrderKind == models.KindTrailingStop {
+		if req.TrailType == "" {
+			req.TrailType = models.TrailAbsolute
+		}
+		if req.TrailType != models.TrailAbsolute && req.TrailType != models.TrailPercentage {
+			return nil, nil, fiber.NewError(400, "Trail type must be 'absolute' or 'percentage'")
+		}
+		if !req.TrailOffset.IsPositive() || req.TrailOffset.GreaterThan(maxOrderValue) {
+			return nil, nil, fiber.NewError(400, "Trailing stops need a trail offset greater than 0")
+		}
+		if req.TrailType == models.TrailPercentage && req.TrailOffset.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+			return nil, nil, fiber.NewError(400, "Percentage trail offset must be between 0 and 1")
+		}
+		isStop = true
+	} else if !req.TrailOffset.IsZero() || req.TrailType != "" {
+		return nil, nil, fiber.NewError(400, "Only trailing stops can have a trail offset")
+	}
+
+	if req.Price.GreaterThan(maxOrderValue) || req.Amount.GreaterThan(maxOrderValue) || req.TriggerPrice.GreaterThan(maxOrderValue) {
+		return nil, nil, fiber.NewError(400, "Price and amount must be less than 10000000000")
+	}
+
+	req.TimeInForce = strings.ToUpper(strings.TrimSpace(req.TimeInForce))
+	if req.TimeInForce == "" {
+		req.TimeInForce = models.TimeInForceGTC
+		if !(req.OrderKind == models.KindLimit || req.OrderKind == models.KindStopLimit) {
+			req.TimeInForce = models.TimeInForceIOC
+		}
+	}
+
+	switch req.TimeInForce {
+	case models.TimeInForceGTC, models.TimeInForceGTD:
+		if !(req.OrderKind == models.KindLimit || req.OrderKind == models.KindStopLimit) {
+			return nil, nil, fiber.NewError(400, "Market and stop orders must be IOC or FOK")
+		}
+	case models.TimeInForceIOC, models.TimeInForceFOK:
+	default:
+		return nil, nil, fiber.NewError(400, "Time in force must be 'GTC', 'IOC', 'FOK' or 'GTD'")
+	}
+
+	if req.PostOnly && !(req.OrderKind == models.KindLimit &&
+		(req.TimeInForce == models.TimeInForceGTC || req.TimeInForce == models.TimeInForceGTD)) {
+		return nil, nil, fiber.NewError(400, "Post-only is only available for GTC and GTD limit orders")
+	}
+	if req.DisplayAmount.IsNegative() {
+		return nil, nil, fiber.NewError(400, "Display amount cannot be negative")
+	}
+	if req.DisplayAmount.IsPositive() {
+		if !((req.OrderKind == models.KindLimit || req.OrderKind == models.KindStopLimit) &&
+			(req.TimeInForce == models.TimeInForceGTC || req.TimeInForce == models.TimeInForceGTD)) {
+			return nil, nil, fiber.NewError(400, "Iceberg orders must be GTC or GTD limit or stop-limit orders")
+		}
+		if req.DisplayAmount.GreaterThanOrEqual(req.Amount) {
+			return nil, nil, fiber.NewError(400, "Display amount must be less than the amount")
+		}
+	}
+	req.STPMode = strings.ToLower(strings.TrimSpace(req.STPMode))
+	if req.STPMode != "" && !models.ValidSTPMode(req.STPMode) {
+		return nil, nil, fiber.NewError(400, "Self-trade prevention mode must be 'none', 'cancel_newest', 'cancel_oldest', 'cancel_both' or 'decrement_cancel'")
+	}
+	req.SessionID = strings.TrimSpace(req.SessionID)
+	if req.PostOnlyReprice && !req.PostOnly {
+		return nil, nil, fiber.NewError(400, "post_only_reprice requires post_only")
+	}
+
+	if req.TimeInForce == models.TimeInForceGTD {
+		if req.ExpiresAt == nil || !req.ExpiresAt.After(time.Now()) {
+			return nil, nil, fiber.NewError(400, "GTD orders need an expires_at in the future")
+		}
+		expiresAt := req.ExpiresAt.UTC()
+		req.ExpiresAt = &expiresAt
+	} else if req.ExpiresAt != nil {
+		return nil, nil, fiber.NewError(400, "Only GTD orders can have expires_at")
+	}
+
+	status := models.StatusActive
+	if isStop {
+		status = models.StatusPendingTrigger
 	}
 
 	order := &models.Order{
-		UserID:    userID,
-		Username:  username,
-		OrderType: req.OrderType,
-		Price:     req.Price,
-		Amount:    req.Amount,
-		Status:    "active",
+		UserID:          userID,
+		Username:        username,
+		Symbol:          market.Symbol,
+		OrderType:       req.OrderType,
+		OrderKind:       req.OrderKind,
+		Price:           req.Price,
+		TriggerPrice:    req.TriggerPrice,
+		TrailOffset:     req.TrailOffset,
+		TrailType:       req.TrailType,
+		Amount:          req.Amount,
+		RemainingAmount: req.Amount,
+		Status:          status,
+		TimeInForce:     req.TimeInForce,
+		ExpiresAt:       req.ExpiresAt,
+		DisplayAmount:   req.DisplayAmount,
+		VisibleAmount:   req.DisplayAmount,
+		PostOnly:        req.PostOnly,
+		ReduceOnly:      req.ReduceOnly,
+		SessionID:       req.SessionID,
+		STPMode:         req.STPMode,
+		WorstPrice:      req.WorstPrice,
+		MaxSlippage:     req.MaxSlippage,
+		RepriceOnCross:  req.PostOnlyReprice,
+	}
+
+	if err := engine.ValidateOrder(market, order); err != nil {
+		return nil, nil, err
+	}
+
+	return market, order, nil
+}
+
+// CreateOrderGroup places an OCO or bracket group
+func (h *OrderHandler) CreateOrderGroup(c *fiber.Ctx) error {
+	var req models.CreateOrderGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if req.TakeProfit == nil || req.StopLoss == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "take_profit and stop_loss are required"})
+	}
+
+	var legs []*models.CreateOrderRequest
+	var roles []string
+	switch req.GroupType {
+	case models.GroupOCO:
+		if req.Entry != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "OCO groups have no entry"})
+		}
+		if req.TakeProfit.OrderType != req.StopLoss.OrderType || !req.TakeProfit.Amount.Equal(req.StopLoss.Amount) {
+			return c.Status(400).JSON(fiber.Map{"error": "OCO legs must have the same side and amount"})
+		}
+		if !strings.EqualFold(strings.TrimSpace(req.TakeProfit.Symbol), strings.TrimSpace(req.StopLoss.Symbol)) {
+			return c.Status(400).JSON(fiber.Map{"error": "OCO legs must be in the same market"})
+		}
+	case models.GroupBracket:
+		if req.Entry == nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Bracket groups need an entry"})
+		}
+		if req.Entry.OrderKind != "" && req.Entry.OrderKind != models.KindLimit && req.Entry.OrderKind != models.KindMarket {
+			return c.Status(400).JSON(fiber.Map{"error": "Bracket entry must be a limit or market order"})
+		}
+		for _, leg := range []*models.CreateOrderRequest{req.TakeProfit, req.StopLoss} {
+			if leg.Symbol == "" {
+				leg.Symbol = req.Entry.Symbol
+			}
+			if !strings.EqualFold(strings.TrimSpace(leg.Symbol), strings.TrimSpace(req.Entry.Symbol)) {
+				return c.Status(400).JSON(fiber.Map{"error": "Bracket orders must be in the same market"})
+			}
+			if leg.OrderType == "" {
+				leg.OrderType = oppositeSide(req.Entry.OrderType)
+			}
+			if leg.OrderType == req.Entry.OrderType {
+				return c.Status(400).JSON(fiber.Map{"error": "Take-profit and stop-loss must be on the opposite side of the entry"})
+			}
+			if leg.Amount.IsZero() {
+				leg.Amount = req.Entry.Amount
+			}
+			if !leg.Amount.Equal(req.Entry.Amount) {
+				return c.Status(400).JSON(fiber.Map{"error": "Take-profit and stop-loss must be for the entry amount"})
+			}
+			if leg.PostOnly || leg.ReduceOnly {
+				return c.Status(400).JSON(fiber.Map{"error": "Take-profit and stop-loss cannot be post-only or reduce-only"})
+			}
+		}
+		legs = append(legs, req.Entry)
+		roles = append(roles, models.RoleEntry)
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "Group type must be 'oco' or 'bracket'"})
+	}
+
+	if req.TakeProfit.OrderKind != "" && req.TakeProfit.OrderKind != models.KindLimit {
+		return c.Status(400).JSON(fiber.Map{"error": "Take-profit must be a limit order"})
+	}
+	if req.StopLoss.OrderKind != models.KindStop && req.StopLoss.OrderKind != models.KindStopLimit &&
+		req.StopLoss.OrderKind != models.KindTrailingStop {
+		return c.Status(400).JSON(fiber.Map{"error": "Stop-loss must be a stop, stop-limit or trailing stop order"})
+	}
+	legs = append(legs, req.TakeProfit, req.StopLoss)
+	roles = append(roles, models.RoleTakeProfit, models.RoleStopLoss)
+
+	var market *models.Market
+	var orders []*models.Order
+	for i, leg := range legs {
+		legMarket, order, err := h.newOrder(c, leg)
+		if err != nil {
+			return orderErrorResponse(c, err, "Failed to create order group")
+		}
+		order.GroupRole = roles[i]
+		if req.GroupType == models.GroupBracket && roles[i] != models.RoleEntry {
+			order.Status = models.StatusPendingActivation
+		}
+		market = legMarket
+		orders = append(orders, order)
 	}
 
-	if err := h.orderRepo.Create(c.Context(), order); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create order"})
+	group := &models.OrderGroup{
+		UserID:    c.Locals("userID").(int64),
+		Symbol:    market.Symbol,
+		GroupType: req.GroupType,
 	}
 
-	// Broadcast to all WebSocket clients
-	h.hub.BroadcastOrder(order)
+	result, err := h.engine.PlaceGroup(c.Context(), market, group, orders)
+	if err != nil {
+		return orderErrorResponse(c, err, "Failed to create order group")
+	}
 
-	return c.Status(201).JSON(order)
+	response := models.OrderGroupResponse{OrderGroup: result.Group, Orders: result.Orders, Fills: models.UserFills(result.Trades, group.UserID)}
+
+	return c.Status(201).JSON(response)
 }
 
 func (h *OrderHandler) GetMyOrders(c *fiber.Ctx) error {
@@ -75,3 +374,146 @@ func (h *OrderHandler) GetMyOrders(c *fiber.Ctx) error {
 
 	return c.JSON(orders)
 }
+
+func (h *OrderHandler) CancelOrder(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	orderID, err := c.ParamsInt("id")
+	if err != nil || orderID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid order id"})
+	}
+
+	order, err := h.engine.CancelOrder(c.Context(), int64(orderID), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrOrderNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+		case errors.Is(err, repository.ErrOrderNotOpen):
+			return c.Status(409).JSON(fiber.Map{"error": "Order is already filled or cancelled"})
+		default:
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to cancel order"})
+		}
+	}
+
+	return c.JSON(order)
+}
+
+// CancelAllOrders cancels all of the user's open orders, optionally only on
+// one ?side and in one ?symbol, and returns the ids of the cancelled orders
+func (h *OrderHandler) CancelAllOrders(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	side := strings.ToLower(strings.TrimSpace(c.Query("side")))
+	if side != "" && side != "buy" && side != "sell" {
+		return c.Status(400).JSON(fiber.Map{"error": "Side must be 'buy' or 'sell'"})
+	}
+	symbol := strings.ToUpper(strings.TrimSpace(c.Query("symbol")))
+
+	orders, err := h.engine.CancelAll(c.Context(), userID, repository.CancelFilter{Side: side, Symbol: symbol})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to cancel orders"})
+	}
+
+	ids := make([]int64, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
+	}
+
+	return c.JSON(fiber.Map{"cancelled": ids})
+}
+
+// AmendOrder changes the price and/or amount of one of the user's open orders
+func (h *OrderHandler) AmendOrder(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	orderID, err := c.ParamsInt("id")
+	if err != nil || orderID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid order id"})
+	}
+
+	var req models.AmendOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if req.Price.IsNegative() || req.Amount.IsNegative() {
+		return c.Status(400).JSON(fiber.Map{"error": "Price and amount must be greater than 0"})
+	}
+	if req.Price.IsZero() && req.Amount.IsZero() {
+		return c.Status(400).JSON(fiber.Map{"error": "Nothing to amend; give a new price or amount"})
+	}
+	if req.Price.GreaterThan(maxOrderValue) || req.Amount.GreaterThan(maxOrderValue) {
+		return c.Status(400).JSON(fiber.Map{"error": "Price and amount must be less than 10000000000"})
+	}
+
+	order, err := h.orderRepo.GetByID(c.Context(), int64(orderID), userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to amend order"})
+	}
+
+	market, err := h.marketRepo.GetBySymbol(c.Context(), order.Symbol)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to amend order"})
+	}
+
+	amended, err := h.engine.AmendOrder(c.Context(), market, order.ID, userID, req.Price, req.Amount)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrOrderNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+		case errors.Is(err, repository.ErrOrderNotOpen):
+			return c.Status(409).JSON(fiber.Map{"error": "Order is already filled or cancelled"})
+		default:
+			return orderErrorResponse(c, err, "Failed to amend order")
+		}
+	}
+
+	return c.JSON(amended)
+}
+
+// GetOrderEvents returns the history of one of the user's orders
+func (h *OrderHandler) GetOrderEvents(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	orderID, err := c.ParamsInt("id")
+	if err != nil || orderID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid order id"})
+	}
+
+	if _, err := h.orderRepo.GetByID(c.Context(), int64(orderID), userID); err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get order events"})
+	}
+
+	events, err := h.orderEventRepo.GetByOrderID(c.Context(), int64(orderID), userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get order events"})
+	}
+
+	return c.JSON(events)
+}
+
+// orderErrorResponse reports a trading rule violation with its code, a bad
+// request with its message, and any other error as a generic server failure
+func orderErrorResponse(c *fiber.Ctx, err error, message string) error {
+	var orderErr *engine.OrderError
+	if errors.As(err, &orderErr) {
+		return c.Status(400).JSON(orderErr)
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": message})
+}
+
+func oppositeSide(side string) string {
+	if side == "buy" {
+		return "sell"
+	}
+	return "buy"
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"crypto-orderbook/internal/models"
+	"crypto-orderbook/internal/repository"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultTradeLimit = 50
+	maxTradeLimit     = 500
+)
+
+type TradeHandler struct {
+	tradeRepo  *repository.TradeRepository
+	marketRepo *repository.MarketRepository
+}
+
+func NewTradeHandler(tradeRepo *repository.TradeRepository, marketRepo *repository.MarketRepository) *TradeHandler {
+	return &TradeHandler{tradeRepo: tradeRepo, marketRepo: marketRepo}
+}
+
+func (h *TradeHandler) GetTrades(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultTradeLimit)
+	if limit <= 0 || limit > maxTradeLimit {
+		return c.Status(400).JSON(fiber.Map{"error": "Limit must be between 1 and 500"})
+	}
+
+	// No symbol means trades of every market
+	symbol := strings.ToUpper(strings.TrimSpace(c.Query("symbol")))
+	if symbol != "" {
+		if _, err := h.marketRepo.GetBySymbol(c.Context(), symbol); err != nil {
+			if errors.Is(err, repository.ErrMarketNotFound) {
+				return c.Status(404).JSON(fiber.Map{"error": "Market not found"})
+			}
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to get trades"})
+		}
+	}
+
+	trades, err := h.tradeRepo.GetRecent(c.Context(), symbol, limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get trades"})
+	}
+
+	views := make([]models.PublicTrade, len(trades))
+	for i := range trades {
+		views[i] = trades[i].PublicView()
+	}
+
+	return c.JSON(views)
+}
@@ -1,22 +1,80 @@
 package handlers
 
 import (
+	"crypto-orderbook/internal/config"
+	"crypto-orderbook/internal/utils"
 	"crypto-orderbook/internal/websocket"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	ws "github.com/gofiber/websocket/v2"
 )
 
+// maxGracePeriod bounds how long a cancel-on-disconnect session may wait for
+// its client to come back
+const maxGracePeriod = 5 * time.Minute
+
 type WebSocketHandler struct {
 	hub *websocket.Hub
+	cfg *config.Config
 }
 
-func NewWebSocketHandler(hub *websocket.Hub) *WebSocketHandler {
-	return &WebSocketHandler{hub: hub}
+func NewWebSocketHandler(hub *websocket.Hub, cfg *config.Config) *WebSocketHandler {
+	return &WebSocketHandler{hub: hub, cfg: cfg}
 }
 
+// HandleWebSocket streams live events. Clients subscribe to channels such as
+// book.BTC-USDT, trades.BTC-USDT, ticker.BTC-USDT and depth.BTC-USDT with
+// {"type":"subscribe","channels":[...]}, leave them with "unsubscribe" and
+// see their subscriptions with "list"; every command is acknowledged.
+// Channel messages are numbered per channel, and a client that reconnects
+// can send {"type":"resume","channel":...,"sequence":N} to get what it
+// missed after N, or "snapshot_required" if that is no longer kept.
+// Connecting with ?symbol=BTC-USDT subscribes to that market's channels
+// straight away.
+//
+// A client authenticates by connecting with ?token=<jwt> or by sending
+// {"type":"auth","token":...} later. Either way it is subscribed to the
+// private orders channel, which carries only its user's order updates,
+// fills, balance changes, trailing stop trigger updates and prevented
+// self-trades, numbered per user.
+//
+// An authenticated client may add cancel_on_disconnect=true and optionally
+// grace_period=<seconds>. It is then sent a session id to place its orders
+// with, and those orders are cancelled once it has been disconnected for the
+// grace period. Reconnecting with ?session_id=<id> in time resumes the
+// session.
 func (h *WebSocketHandler) HandleWebSocket(c *ws.Conn) {
-	client := websocket.NewClient(h.hub, c)
+	symbol := strings.ToUpper(strings.TrimSpace(c.Query("symbol")))
+	userID, _ := c.Locals("userID").(int64)
+
+	var sessionID string
+	if resume, _ := c.Locals("resumeSession").(string); resume != "" {
+		if !h.hub.ResumeSession(resume, userID) {
+			c.WriteJSON(fiber.Map{"type": "error", "error": "Session has ended"})
+			c.Close()
+			return
+		}
+		sessionID = resume
+	} else if grace, ok := c.Locals("sessionGrace").(time.Duration); ok {
+		sessionID = h.hub.OpenSession(userID, grace)
+	}
+
+	var channels []string
+	if userID != 0 {
+		channels = append(channels, websocket.ChannelOrders)
+	}
+	if symbol != "" {
+		channels = append(channels,
+			websocket.BookChannel(symbol),
+			websocket.TradesChannel(symbol),
+			websocket.TickerChannel(symbol),
+			websocket.DepthChannel(symbol),
+		)
+	}
+
+	client := websocket.NewClient(h.hub, c, userID, sessionID, channels)
 	h.hub.Register(client)
 
 	go client.WritePump()
@@ -25,9 +83,36 @@ func (h *WebSocketHandler) HandleWebSocket(c *ws.Conn) {
 
 func (h *WebSocketHandler) UpgradeMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		if ws.IsWebSocketUpgrade(c) {
-			return c.Next()
+		if !ws.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
 		}
-		return fiber.ErrUpgradeRequired
+
+		var userID int64
+		if token := c.Query("token"); token != "" {
+			claims, err := utils.ValidateToken(token, h.cfg.JWT.Secret)
+			if err != nil {
+				return c.Status(401).JSON(fiber.Map{"error": "Invalid or expired token"})
+			}
+			userID = claims.UserID
+			c.Locals("userID", userID)
+		}
+
+		resume := c.Query("session_id")
+		cancelOnDisconnect := c.QueryBool("cancel_on_disconnect")
+		if (resume != "" || cancelOnDisconnect) && userID == 0 {
+			return c.Status(401).JSON(fiber.Map{"error": "Cancel-on-disconnect sessions need a token"})
+		}
+
+		if resume != "" {
+			c.Locals("resumeSession", resume)
+		} else if cancelOnDisconnect {
+			grace := time.Duration(c.QueryInt("grace_period", 0)) * time.Second
+			if grace < 0 || grace > maxGracePeriod {
+				return c.Status(400).JSON(fiber.Map{"error": "Grace period must be between 0 and 300 seconds"})
+			}
+			c.Locals("sessionGrace", grace)
+		}
+
+		return c.Next()
 	}
 }
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"crypto-orderbook/internal/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminMiddleware lets through only users listed in ADMIN_USER_IDS. It runs
+// after AuthMiddleware.
+func AdminMiddleware(cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("userID").(int64)
+		if !cfg.Funding.IsAdmin(userID) {
+			return c.Status(403).JSON(fiber.Map{
+				"error": "Admin access required",
+			})
+		}
+
+		return c.Next()
+	}
+}
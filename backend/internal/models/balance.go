@@ -0,0 +1,42 @@
+package models
+
+import (
+	"crypto-orderbook/internal/decimal"
+	"time"
+)
+
+// Ledger entry types
+const (
+	EntryLock       = "lock"       // Funds reserved for an order
+	EntryRelease    = "release"    // Reserved funds handed back
+	EntryTrade      = "trade"      // Funds paid or received in an execution
+	EntryDeposit    = "deposit"    // A deposit credited
+	EntryWithdrawal = "withdrawal" // A withdrawal requested, approved, rejected or completed
+	EntryFee        = "fee"        // The fee of an execution
+)
+
+// Balance is what a user holds of one asset. Locked funds are reserved for
+// open orders and cannot be used for anything else.
+type Balance struct {
+	UserID    int64           `json:"-"`
+	Asset     string          `json:"asset"`
+	Available decimal.Decimal `json:"available"`
+	Locked    decimal.Decimal `json:"locked"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// LedgerEntry is one movement of a user's balance of an asset. The ledger is
+// append-only; balances are the sum of their entries.
+type LedgerEntry struct {
+	ID              int64           `json:"id"`
+	UserID          int64           `json:"user_id"`
+	Asset           string          `json:"asset"`
+	EntryType       string          `json:"entry_type"`
+	AvailableChange decimal.Decimal `json:"available_change"`
+	LockedChange    decimal.Decimal `json:"locked_change"`
+	OrderID         *int64          `json:"order_id,omitempty"`
+	TradeID         *int64          `json:"trade_id,omitempty"`
+	DepositID       *int64          `json:"deposit_id,omitempty"`
+	WithdrawalID    *int64          `json:"withdrawal_id,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "crypto-orderbook/internal/decimal"
+
+// PriceLevel is the total amount shown at one price of an aggregated book
+type PriceLevel struct {
+	Price  decimal.Decimal `json:"price"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// DepthUpdate is a change to a market's aggregated book. Each level carries
+// the new total amount at its price; a zero amount removes the price.
+type DepthUpdate struct {
+	Symbol string       `json:"symbol"`
+	Bids   []PriceLevel `json:"bids"` // highest price first
+	Asks   []PriceLevel `json:"asks"` // lowest price first
+}
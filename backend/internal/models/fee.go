@@ -0,0 +1,46 @@
+package models
+
+import (
+	"crypto-orderbook/internal/decimal"
+	"time"
+)
+
+// FeeTier is a level of the fee schedule, reached by a user's trading
+// volume over the last 30 days. Its discounts apply to the maker and taker
+// rates of every market ("0.25" = 25% off).
+type FeeTier struct {
+	Tier          int             `json:"tier"`
+	MinVolume     decimal.Decimal `json:"min_volume"`
+	MakerDiscount decimal.Decimal `json:"maker_discount"`
+	TakerDiscount decimal.Decimal `json:"taker_discount"`
+}
+
+// MakerRate is the maker fee rate of a market for users in the tier
+func (t *FeeTier) MakerRate(market *Market) decimal.Decimal {
+	return market.MakerFeeRate.Sub(market.MakerFeeRate.Mul(t.MakerDiscount))
+}
+
+// TakerRate is the taker fee rate of a market for users in the tier
+func (t *FeeTier) TakerRate(market *Market) decimal.Decimal {
+	return market.TakerFeeRate.Sub(market.TakerFeeRate.Mul(t.TakerDiscount))
+}
+
+// MarketFees are the rates a user pays in one market
+type MarketFees struct {
+	Symbol    string          `json:"symbol"`
+	MakerRate decimal.Decimal `json:"maker_rate"`
+	TakerRate decimal.Decimal `json:"taker_rate"`
+}
+
+// FeeSchedule is a user's current fee tier and what it takes to reach the
+// next one. Volume is measured in VolumeAsset and last recomputed at
+// UpdatedAt.
+type FeeSchedule struct {
+	Tier             FeeTier         `json:"tier"`
+	Volume30d        decimal.Decimal `json:"volume_30d"`
+	VolumeAsset      string          `json:"volume_asset"`
+	UpdatedAt        *time.Time      `json:"updated_at,omitempty"`
+	NextTier         *FeeTier        `json:"next_tier,omitempty"`
+	VolumeToNextTier decimal.Decimal `json:"volume_to_next_tier,omitzero"`
+	Markets          []MarketFees    `json:"markets"`
+}
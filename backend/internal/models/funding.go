@@ -0,0 +1,58 @@
+package models
+
+import (
+	"crypto-orderbook/internal/decimal"
+	"time"
+)
+
+// Deposit statuses
+const (
+	DepositPending   = "pending"   // Waiting for confirmations
+	DepositCompleted = "completed" // Credited to the balance
+)
+
+// Withdrawal statuses
+const (
+	WithdrawalPending   = "pending"   // Funds locked, waiting for an admin
+	WithdrawalApproved  = "approved"  // Approved, waiting to be sent
+	WithdrawalCompleted = "completed" // Sent; the funds have left the balance
+	WithdrawalRejected  = "rejected"  // Refused; the funds are available again
+)
+
+// Deposit is simulated funding of a user's balance. It is credited once it
+// has RequiredConfirmations confirmations.
+type Deposit struct {
+	ID                    int64           `json:"id"`
+	UserID                int64           `json:"user_id"`
+	Asset                 string          `json:"asset"`
+	Amount                decimal.Decimal `json:"amount"`
+	Status                string          `json:"status"`
+	Confirmations         int             `json:"confirmations"`
+	RequiredConfirmations int             `json:"required_confirmations"`
+	CreatedAt             time.Time       `json:"created_at"`
+	CompletedAt           *time.Time      `json:"completed_at,omitempty"`
+}
+
+// Withdrawal is a simulated transfer out of a user's balance. Its funds are
+// locked from the request until it is completed or rejected.
+type Withdrawal struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"user_id"`
+	Asset     string          `json:"asset"`
+	Amount    decimal.Decimal `json:"amount"`
+	Address   string          `json:"address"`
+	Status    string          `json:"status"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type CreateDepositRequest struct {
+	Asset  string          `json:"asset" validate:"required"`
+	Amount decimal.Decimal `json:"amount" validate:"required,gt=0"`
+}
+
+type CreateWithdrawalRequest struct {
+	Asset   string          `json:"asset" validate:"required"`
+	Amount  decimal.Decimal `json:"amount" validate:"required,gt=0"`
+	Address string          `json:"address" validate:"required,max=255"`
+}
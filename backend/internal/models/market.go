@@ -0,0 +1,32 @@
+package models
+
+import (
+	"crypto-orderbook/internal/decimal"
+	"time"
+)
+
+// Market statuses
+const (
+	MarketActive = "active"
+	MarketHalted = "halted"
+)
+
+// Market is a tradable pair such as BTC-USDT, where prices are quoted in the
+// quote asset and amounts are in the base asset. A zero MinQuantity,
+// MinNotional or MaxNotional means the rule is not enforced. The fee rates
+// are fractions of what each side receives ("0.001" = 0.1%) before the
+// discount of the user's fee tier.
+type Market struct {
+	Symbol       string          `json:"symbol"`
+	BaseAsset    string          `json:"base_asset"`
+	QuoteAsset   string          `json:"quote_asset"`
+	Status       string          `json:"status"`    // "active" or "halted"
+	TickSize     decimal.Decimal `json:"tick_size"` // Price increment
+	LotSize      decimal.Decimal `json:"lot_size"`  // Quantity step
+	MinQuantity  decimal.Decimal `json:"min_quantity"`
+	MinNotional  decimal.Decimal `json:"min_notional"` // price * amount, in the quote asset
+	MaxNotional  decimal.Decimal `json:"max_notional"`
+	MakerFeeRate decimal.Decimal `json:"maker_fee_rate"`
+	TakerFeeRate decimal.Decimal `json:"taker_fee_rate"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
@@ -1,25 +1,221 @@
 package models
 
-import "time"
+import (
+	"crypto-orderbook/internal/decimal"
+	"time"
+)
+
+// Order statuses
+const (
+	StatusActive            = "active"
+	StatusPartiallyFilled   = "partially_filled"
+	StatusFilled            = "filled"
+	StatusCancelled         = "cancelled"
+	StatusExpired           = "expired"
+	StatusPendingTrigger    = "pending_trigger"    // Stop order waiting for its trigger price
+	StatusPendingActivation = "pending_activation" // Bracket leg waiting for its entry to fill
+)
+
+// Time in force
+const (
+	TimeInForceGTC = "GTC" // Good til cancelled
+	TimeInForceIOC = "IOC" // Immediate or cancel: the unfilled rest is cancelled
+	TimeInForceFOK = "FOK" // Fill or kill: fills completely or not at all
+	TimeInForceGTD = "GTD" // Good til date: expires at ExpiresAt
+)
+
+// Order kinds
+const (
+	KindLimit     = "limit"
+	KindMarket    = "market"
+	KindStop      = "stop"       // Becomes a market order once triggered
+	KindStopLimit = "stop_limit" // Becomes a limit order once triggered
+
+	// KindTrailingStop is a stop order whose trigger follows the last trade
+	// price at a fixed distance while the price moves in the holder's favour.
+	// It becomes a market order once triggered.
+	KindTrailingStop = "trailing_stop"
+)
+
+// How the distance of a trailing stop from the price is given
+const (
+	TrailAbsolute   = "absolute"   // In quote currency
+	TrailPercentage = "percentage" // As a fraction of the price, "0.01" = 1%
+)
+
+// Self-trade prevention modes. When an incoming order would trade against a
+// resting order of the same user, its mode decides what happens instead.
+const (
+	STPNone            = "none"             // Let the orders trade
+	STPCancelNewest    = "cancel_newest"    // Cancel the rest of the incoming order
+	STPCancelOldest    = "cancel_oldest"    // Cancel the resting order and keep matching
+	STPCancelBoth      = "cancel_both"      // Cancel both orders
+	STPDecrementCancel = "decrement_cancel" // Reduce both by the smaller size, cancelling the smaller one
+)
+
+// ValidSTPMode reports whether mode is a self-trade prevention mode
+func ValidSTPMode(mode string) bool {
+	switch mode {
+	case STPNone, STPCancelNewest, STPCancelOldest, STPCancelBoth, STPDecrementCancel:
+		return true
+	}
+	return false
+}
 
 type Order struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
-	Username  string    `json:"username,omitempty"` // For display purposes
-	OrderType string    `json:"order_type"`         // "buy" or "sell"
-	Price     float64   `json:"price"`
-	Amount    float64   `json:"amount"`
-	Status    string    `json:"status"` // "active", "filled", "cancelled"
-	CreatedAt time.Time `json:"created_at"`
+	ID              int64           `json:"id"`
+	UserID          int64           `json:"user_id"`
+	Username        string          `json:"username,omitempty"` // For display purposes
+	Symbol          string          `json:"symbol"`
+	OrderType       string          `json:"order_type"`             // "buy" or "sell"
+	OrderKind       string          `json:"order_kind"`             // "limit", "market", "stop", "stop_limit" or "trailing_stop"
+	Price           decimal.Decimal `json:"price,omitzero"`         // Not set for market and stop orders
+	TriggerPrice    decimal.Decimal `json:"trigger_price,omitzero"` // Stop orders only; the current level for trailing stops
+	TrailOffset     decimal.Decimal `json:"trail_offset,omitzero"`  // Trailing stops only
+	TrailType       string          `json:"trail_type,omitempty"`   // Trailing stops only: "absolute" or "percentage"
+	Amount          decimal.Decimal `json:"amount"`                 // Original quantity
+	FilledAmount    decimal.Decimal `json:"filled_amount"`
+	RemainingAmount decimal.Decimal `json:"remaining_amount"`
+	AvgFillPrice    decimal.Decimal `json:"avg_fill_price"`
+	Status          string          `json:"status"` // "pending_activation", "pending_trigger", "active", "partially_filled", "filled", "cancelled", "expired"
+	TimeInForce     string          `json:"time_in_force"`
+	ExpiresAt       *time.Time      `json:"expires_at,omitempty"`    // GTD only
+	DisplayAmount   decimal.Decimal `json:"display_amount,omitzero"` // Iceberg orders: size of each visible slice
+	VisibleAmount   decimal.Decimal `json:"visible_amount,omitzero"` // Iceberg orders: what is left of the current slice
+	PostOnly        bool            `json:"post_only"`               // Only ever adds liquidity
+	ReduceOnly      bool            `json:"reduce_only"`             // Never increases the net position
+	GroupID         *int64          `json:"group_id,omitempty"`      // OCO or bracket group
+	GroupRole       string          `json:"group_role,omitempty"`    // "entry", "take_profit" or "stop_loss"
+	SessionID       string          `json:"session_id,omitempty"`    // Cancelled when this WebSocket session ends
+	STPMode         string          `json:"stp_mode,omitempty"`      // Self-trade prevention mode
+	LockedAmount    decimal.Decimal `json:"locked_amount,omitzero"`  // Reserved while live: quote asset for buys, base asset for sells
+	CreatedAt       time.Time       `json:"created_at"`
+
+	// Execution bounds of a market order; they are not stored
+	WorstPrice  decimal.Decimal `json:"-"`
+	MaxSlippage decimal.Decimal `json:"-"`
+
+	// RepriceOnCross moves a crossing post-only order one tick behind the
+	// best opposite price instead of rejecting it; it is not stored
+	RepriceOnCross bool `json:"-"`
+}
+
+// IsOpen reports whether the order can still execute
+func (o *Order) IsOpen() bool {
+	return o.Status == StatusActive || o.Status == StatusPartiallyFilled
+}
+
+// IsStop reports whether the order waits for a trigger price before it
+// enters the book
+func (o *Order) IsStop() bool {
+	return o.OrderKind == KindStop || o.OrderKind == KindStopLimit || o.OrderKind == KindTrailingStop
+}
+
+// HasPrice reports whether the order executes with a limit price
+func (o *Order) HasPrice() bool {
+	return o.OrderKind == KindLimit || o.OrderKind == KindStopLimit
+}
+
+// IsIceberg reports whether the order shows only a slice of its size
+func (o *Order) IsIceberg() bool {
+	return o.DisplayAmount.IsPositive()
+}
+
+// Shown is the part of the order other traders can see and trade against
+// before it is replenished
+func (o *Order) Shown() decimal.Decimal {
+	if o.IsIceberg() {
+		return o.VisibleAmount
+	}
+	return o.RemainingAmount
+}
+
+// PublicView returns the order as other traders may see it. What only
+// concerns its owner, such as its session, reserved funds, self-trade
+// prevention and group, is left out. An iceberg order appears as an order
+// for its current slice; its total size and hidden reserve are left out too.
+func (o Order) PublicView() Order {
+	o.LockedAmount = decimal.Zero
+	o.ReduceOnly = false
+	o.GroupID = nil
+	o.GroupRole = ""
+	o.SessionID = ""
+	o.STPMode = ""
+	if !o.IsIceberg() {
+		return o
+	}
+
+	o.Amount = o.DisplayAmount
+	o.RemainingAmount = o.VisibleAmount
+	o.FilledAmount = o.DisplayAmount.Sub(o.VisibleAmount)
+	o.AvgFillPrice = decimal.Zero
+	o.DisplayAmount = decimal.Zero
+	o.VisibleAmount = decimal.Zero
+	return o
+}
+
+// CanRest reports whether an unfilled remainder may wait in the book rather
+// than being cancelled straight away
+func (o *Order) CanRest() bool {
+	return o.HasPrice() && (o.TimeInForce == TimeInForceGTC || o.TimeInForce == TimeInForceGTD)
 }
 
 type CreateOrderRequest struct {
-	OrderType string  `json:"order_type" validate:"required,oneof=buy sell"`
-	Price     float64 `json:"price" validate:"required,gt=0"`
-	Amount    float64 `json:"amount" validate:"required,gt=0"`
+	Symbol    string          `json:"symbol" validate:"required"`
+	OrderType string          `json:"order_type" validate:"required,oneof=buy sell"`
+	OrderKind string          `json:"order_kind" validate:"omitempty,oneof=limit market stop stop_limit trailing_stop"` // Defaults to "limit"
+	Price     decimal.Decimal `json:"price" validate:"omitempty,gt=0"`
+	Amount    decimal.Decimal `json:"amount" validate:"required,gt=0"`
+
+	// Stop and stop-limit orders only: a buy triggers once the last trade
+	// price rises to trigger_price, a sell once it falls to it
+	TriggerPrice decimal.Decimal `json:"trigger_price"`
+
+	// Trailing stops only: the trigger starts trail_offset away from the last
+	// trade price and follows it as it moves in the order's favour.
+	// trail_type is "absolute" (the default) or "percentage".
+	TrailOffset decimal.Decimal `json:"trail_offset"`
+	TrailType   string          `json:"trail_type" validate:"omitempty,oneof=absolute percentage"`
+
+	// Defaults to "GTC" for limit and stop-limit orders and "IOC" for market,
+	// stop and trailing stop orders, which also accept "FOK". "GTD" requires expires_at.
+	TimeInForce string     `json:"time_in_force" validate:"omitempty,oneof=GTC IOC FOK GTD"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+
+	// Limit and stop-limit GTC/GTD orders only: shows display_amount at a time
+	// and keeps the rest of the amount hidden
+	DisplayAmount decimal.Decimal `json:"display_amount"`
+
+	// Limit GTC/GTD orders only: post_only rejects an order that would take
+	// liquidity, or re-prices it one tick away with post_only_reprice
+	PostOnly        bool `json:"post_only"`
+	PostOnlyReprice bool `json:"post_only_reprice"`
+
+	// Rejects the order if it could increase the net position in the market
+	ReduceOnly bool `json:"reduce_only"`
+
+	// Self-trade prevention mode; defaults to the account's default_stp_mode
+	STPMode string `json:"stp_mode" validate:"omitempty,oneof=none cancel_newest cancel_oldest cancel_both decrement_cancel"`
+
+	// Ties the order to a cancel-on-disconnect WebSocket session of the user,
+	// which must be connected
+	SessionID string `json:"session_id"`
+
+	// Market orders only: stop sweeping at worst_price, or once the price
+	// moves more than max_slippage (a fraction, "0.01" = 1%) from the best
+	// opposite price
+	WorstPrice  decimal.Decimal `json:"worst_price"`
+	MaxSlippage decimal.Decimal `json:"max_slippage"`
+}
+
+// OrderResponse is an order together with the fills it produced on entry
+type OrderResponse struct {
+	Order
+	Fills []Fill `json:"fills"`
 }
 
 type OrderBook struct {
+	Symbol     string  `json:"symbol"`
 	BuyOrders  []Order `json:"buy_orders"`
 	SellOrders []Order `json:"sell_orders"`
 }
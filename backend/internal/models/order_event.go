@@ -0,0 +1,57 @@
+package models
+
+import (
+	"crypto-orderbook/internal/decimal"
+	"encoding/json"
+	"time"
+)
+
+// Order event types
+const (
+	EventAmended            = "amended"
+	EventSelfTradePrevented = "self_trade_prevented"
+	EventReduced            = "reduced"
+)
+
+// OrderEvent is an entry in the history of an order
+type OrderEvent struct {
+	ID        int64           `json:"id"`
+	OrderID   int64           `json:"order_id"`
+	UserID    int64           `json:"user_id"`
+	EventType string          `json:"event_type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Amendment is the data of an "amended" event: the price and amount before
+// and after, and whether the order kept its place in the queue. A "reduced"
+// event, for a reduce-only order shrunk because the position moved, carries
+// the same data.
+type Amendment struct {
+	OldPrice     decimal.Decimal `json:"old_price,omitzero"`
+	NewPrice     decimal.Decimal `json:"new_price,omitzero"`
+	OldAmount    decimal.Decimal `json:"old_amount,omitzero"`
+	NewAmount    decimal.Decimal `json:"new_amount,omitzero"`
+	PriorityKept bool            `json:"priority_kept"`
+}
+
+// SelfTradePrevention is the data of a "self_trade_prevented" event: an
+// incoming order that would have traded against a resting order of the same
+// user, and what was done about it under the incoming order's mode
+type SelfTradePrevention struct {
+	Symbol       string          `json:"symbol"`
+	UserID       int64           `json:"-"`
+	Mode         string          `json:"mode"`
+	TakerOrderID int64           `json:"taker_order_id"`
+	MakerOrderID int64           `json:"maker_order_id"`
+	Price        decimal.Decimal `json:"price"`
+	Amount       decimal.Decimal `json:"amount"`    // What would have traded; for decrement_cancel what both orders lost
+	Cancelled    []int64         `json:"cancelled"` // Orders cancelled as a result
+}
+
+// AmendOrderRequest changes the price, the amount or both of an open order.
+// Amount is the new total quantity, including what has already filled.
+type AmendOrderRequest struct {
+	Price  decimal.Decimal `json:"price" validate:"omitempty,gt=0"`
+	Amount decimal.Decimal `json:"amount" validate:"omitempty,gt=0"`
+}
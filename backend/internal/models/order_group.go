@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Order group types
+const (
+	GroupOCO     = "oco"     // A limit and a stop order; a fill on one cancels the other
+	GroupBracket = "bracket" // An entry whose take-profit and stop-loss wait for it to fill
+)
+
+// Roles of the orders in a group
+const (
+	RoleEntry      = "entry"
+	RoleTakeProfit = "take_profit"
+	RoleStopLoss   = "stop_loss"
+)
+
+type OrderGroup struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Symbol    string    `json:"symbol"`
+	GroupType string    `json:"group_type"` // "oco" or "bracket"
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateOrderGroupRequest places every order of a group at once. An OCO
+// group takes a limit take_profit and a stop or stop-limit stop_loss on the
+// same side and for the same amount. A bracket also takes a limit or market
+// entry; its take_profit and stop_loss close the entry, so they take the
+// opposite side, default to the entry's amount and only go live once the
+// entry has filled.
+type CreateOrderGroupRequest struct {
+	GroupType  string              `json:"group_type" validate:"required,oneof=oco bracket"`
+	Entry      *CreateOrderRequest `json:"entry"`
+	TakeProfit *CreateOrderRequest `json:"take_profit" validate:"required"`
+	StopLoss   *CreateOrderRequest `json:"stop_loss" validate:"required"`
+}
+
+// OrderGroupResponse is a group with the current state of its orders and the
+// fills they produced on entry
+type OrderGroupResponse struct {
+	OrderGroup
+	Orders []Order `json:"orders"`
+	Fills  []Fill  `json:"fills"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"crypto-orderbook/internal/decimal"
+	"time"
+)
+
+// Ticker is the top of a market: its last trade price and best bid and ask.
+// A side with no orders, or a market that has not traded, leaves the field
+// out.
+type Ticker struct {
+	Symbol    string          `json:"symbol"`
+	LastPrice decimal.Decimal `json:"last_price,omitzero"`
+	BestBid   decimal.Decimal `json:"best_bid,omitzero"`
+	BestAsk   decimal.Decimal `json:"best_ask,omitzero"`
+	Time      time.Time       `json:"time"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"crypto-orderbook/internal/decimal"
+	"time"
+)
+
+// Trade is a single execution between a resting maker order and an incoming
+// taker order. Each side pays its fee in the asset it receives.
+type Trade struct {
+	ID            int64           `json:"id"`
+	Symbol        string          `json:"symbol"`
+	MakerOrderID  int64           `json:"maker_order_id"`
+	TakerOrderID  int64           `json:"taker_order_id"`
+	MakerUserID   int64           `json:"maker_user_id"`
+	TakerUserID   int64           `json:"taker_user_id"`
+	Price         decimal.Decimal `json:"price"`
+	Amount        decimal.Decimal `json:"amount"`
+	AggressorSide string          `json:"aggressor_side"` // taker's side: "buy" or "sell"
+	MakerFee      decimal.Decimal `json:"maker_fee"`
+	MakerFeeAsset string          `json:"maker_fee_asset"`
+	TakerFee      decimal.Decimal `json:"taker_fee"`
+	TakerFeeAsset string          `json:"taker_fee_asset"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// PublicTrade is a trade as every trader may see it. Who traded is left out,
+// and so are the orders, which would lead back to their owners in the book,
+// and the fees, which give away each side's fee tier. Each side sees its own
+// part in its fills.
+type PublicTrade struct {
+	ID            int64           `json:"id"`
+	Symbol        string          `json:"symbol"`
+	Price         decimal.Decimal `json:"price"`
+	Amount        decimal.Decimal `json:"amount"`
+	AggressorSide string          `json:"aggressor_side"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// PublicView returns the trade as other traders may see it
+func (t *Trade) PublicView() PublicTrade {
+	return PublicTrade{
+		ID:            t.ID,
+		Symbol:        t.Symbol,
+		Price:         t.Price,
+		Amount:        t.Amount,
+		AggressorSide: t.AggressorSide,
+		CreatedAt:     t.CreatedAt,
+	}
+}
+
+// Fill is one side of a trade as its owner sees it
+type Fill struct {
+	TradeID   int64           `json:"trade_id"`
+	OrderID   int64           `json:"order_id"`
+	UserID    int64           `json:"-"`
+	Symbol    string          `json:"symbol"`
+	Side      string          `json:"side"`      // "buy" or "sell"
+	Liquidity string          `json:"liquidity"` // "maker" or "taker"
+	Price     decimal.Decimal `json:"price"`
+	Amount    decimal.Decimal `json:"amount"`
+	Fee       decimal.Decimal `json:"fee"`
+	FeeAsset  string          `json:"fee_asset"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Fills splits a trade into the fill of its maker and that of its taker
+func (t *Trade) Fills() [2]Fill {
+	makerSide := "buy"
+	if t.AggressorSide == "buy" {
+		makerSide = "sell"
+	}
+
+	fill := Fill{
+		TradeID:   t.ID,
+		Symbol:    t.Symbol,
+		Price:     t.Price,
+		Amount:    t.Amount,
+		CreatedAt: t.CreatedAt,
+	}
+	maker, taker := fill, fill
+	maker.OrderID, maker.UserID, maker.Side, maker.Liquidity = t.MakerOrderID, t.MakerUserID, makerSide, "maker"
+	maker.Fee, maker.FeeAsset = t.MakerFee, t.MakerFeeAsset
+	taker.OrderID, taker.UserID, taker.Side, taker.Liquidity = t.TakerOrderID, t.TakerUserID, t.AggressorSide, "taker"
+	taker.Fee, taker.FeeAsset = t.TakerFee, t.TakerFeeAsset
+	return [2]Fill{maker, taker}
+}
+
+// UserFills returns the fills of a user's own orders in trades, leaving out
+// what the other side received and paid
+func UserFills(trades []Trade, userID int64) []Fill {
+	fills := []Fill{}
+	for i := range trades {
+		for _, fill := range trades[i].Fills() {
+			if fill.UserID == userID {
+				fills = append(fills, fill)
+			}
+		}
+	}
+	return fills
+}
@@ -3,11 +3,12 @@ package models
 import "time"
 
 type User struct {
-	ID           int64     `json:"id"`
-	Email        string    `json:"email"`
-	Username     string    `json:"username"`
-	PasswordHash string    `json:"-"` // "-" means don't include in JSON
-	CreatedAt    time.Time `json:"created_at"`
+	ID             int64     `json:"id"`
+	Email          string    `json:"email"`
+	Username       string    `json:"username"`
+	PasswordHash   string    `json:"-"`                // "-" means don't include in JSON
+	DefaultSTPMode string    `json:"default_stp_mode"` // Self-trade prevention mode for orders that don't set one
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 type RegisterRequest struct {
@@ -21,6 +22,11 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// UpdateAccountRequest changes the settings of the user's account
+type UpdateAccountRequest struct {
+	DefaultSTPMode string `json:"default_stp_mode" validate:"required,oneof=none cancel_newest cancel_oldest cancel_both decrement_cancel"`
+}
+
 type AuthResponse struct {
 	Token string `json:"token"`
 	User  User   `json:"user"`
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"crypto-orderbook/internal/models"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+type BalanceRepository struct {
+	db DBTX
+}
+
+func NewBalanceRepository(db DBTX) *BalanceRepository {
+	return &BalanceRepository{db: db}
+}
+
+// GetByUserID retrieves the balances of a user by asset
+func (r *BalanceRepository) GetByUserID(ctx context.Context, userID int64) ([]models.Balance, error) {
+	query := `
+		SELECT asset, available, locked, updated_at
+		FROM balances
+		WHERE user_id = $1
+		ORDER BY asset ASC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balances: %w", err)
+	}
+	defer rows.Close()
+
+	balances := []models.Balance{}
+	for rows.Next() {
+		var balance models.Balance
+		if err := rows.Scan(&balance.Asset, &balance.Available, &balance.Locked, &balance.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan balance: %w", err)
+		}
+		balances = append(balances, balance)
+	}
+
+	return balances, rows.Err()
+}
+
+// Apply changes a balance by a ledger entry, appends the entry to the ledger
+// and returns the new balance. It returns ErrInsufficientFunds, leaving the
+// balance untouched, if either part of the balance would go below zero.
+func (r *BalanceRepository) Apply(ctx context.Context, entry *models.LedgerEntry) (*models.Balance, error) {
+	// Credits may open a balance; debits need one that covers them
+	query := `
+		INSERT INTO balances (user_id, asset, available, locked, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id, asset) DO UPDATE
+		SET available = balances.available + EXCLUDED.available,
+			locked = balances.locked + EXCLUDED.locked,
+			updated_at = NOW()
+		RETURNING user_id, asset, available, locked, updated_at
+	`
+	if entry.AvailableChange.IsNegative() || entry.LockedChange.IsNegative() {
+		query = `
+			UPDATE balances
+			SET available = available + $3, locked = locked + $4, updated_at = NOW()
+			WHERE user_id = $1 AND asset = $2 AND available + $3 >= 0 AND locked + $4 >= 0
+			RETURNING user_id, asset, available, locked, updated_at
+		`
+	}
+
+	var balance models.Balance
+	err := r.db.QueryRow(ctx, query, entry.UserID, entry.Asset, entry.AvailableChange, entry.LockedChange).Scan(
+		&balance.UserID,
+		&balance.Asset,
+		&balance.Available,
+		&balance.Locked,
+		&balance.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInsufficientFunds
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update balance: %w", err)
+	}
+
+	query = `
+		INSERT INTO ledger_entries (
+			user_id, asset, entry_type, available_change, locked_change, order_id, trade_id, deposit_id, withdrawal_id, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		RETURNING id, created_at
+	`
+
+	err = r.db.QueryRow(ctx, query,
+		entry.UserID,
+		entry.Asset,
+		entry.EntryType,
+		entry.AvailableChange,
+		entry.LockedChange,
+		entry.OrderID,
+		entry.TradeID,
+		entry.DepositID,
+		entry.WithdrawalID,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ledger entry: %w", err)
+	}
+
+	return &balance, nil
+}
+
+// GetLedger retrieves the most recent ledger entries of a user, newest
+// first, optionally for one asset only
+func (r *BalanceRepository) GetLedger(ctx context.Context, userID int64, asset string, limit int) ([]models.LedgerEntry, error) {
+	query := `
+		SELECT id, user_id, asset, entry_type, available_change, locked_change, order_id, trade_id, deposit_id, withdrawal_id, created_at
+		FROM ledger_entries
+		WHERE user_id = $1 AND ($2 = '' OR asset = $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, asset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ledger: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.LedgerEntry{}
+	for rows.Next() {
+		var entry models.LedgerEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.UserID,
+			&entry.Asset,
+			&entry.EntryType,
+			&entry.AvailableChange,
+			&entry.LockedChange,
+			&entry.OrderID,
+			&entry.TradeID,
+			&entry.DepositID,
+			&entry.WithdrawalID,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
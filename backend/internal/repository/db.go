@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"crypto-orderbook/internal/decimal"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DBTX is implemented by both *pgxpool.Pool and pgx.Tx, so a repository can
+// run either directly against the pool or inside a transaction
+type DBTX interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// WithTx runs fn inside a transaction, committing if it returns nil and
+// rolling back otherwise
+func WithTx(ctx context.Context, pool *pgxpool.Pool, fn func(tx pgx.Tx) error) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// nullIfZero stores a zero decimal as NULL, for optional columns such as the
+// price of a market order
+func nullIfZero(d decimal.Decimal) any {
+	if d.IsZero() {
+		return nil
+	}
+	return d
+}
+
+// nullIfEmpty stores an empty string as NULL
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"crypto-orderbook/internal/models"
+	"fmt"
+)
+
+const depositColumns = `id, user_id, asset, amount, status, confirmations, required_confirmations, created_at, completed_at`
+
+type DepositRepository struct {
+	db DBTX
+}
+
+func NewDepositRepository(db DBTX) *DepositRepository {
+	return &DepositRepository{db: db}
+}
+
+// Create records a new deposit; one needing no confirmations is created
+// completed
+func (r *DepositRepository) Create(ctx context.Context, deposit *models.Deposit) error {
+	query := `
+		INSERT INTO deposits (user_id, asset, amount, status, required_confirmations, created_at, completed_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), CASE WHEN $4 = 'completed' THEN NOW() END)
+		RETURNING id, created_at, completed_at
+	`
+
+	err := r.db.QueryRow(ctx, query,
+		deposit.UserID,
+		deposit.Asset,
+		deposit.Amount,
+		deposit.Status,
+		deposit.RequiredConfirmations,
+	).Scan(&deposit.ID, &deposit.CreatedAt, &deposit.CompletedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create deposit: %w", err)
+	}
+
+	return nil
+}
+
+// GetByUserID retrieves the deposits of a user, newest first
+func (r *DepositRepository) GetByUserID(ctx context.Context, userID int64) ([]models.Deposit, error) {
+	query := `
+		SELECT ` + depositColumns + `
+		FROM deposits
+		WHERE user_id = $1
+		ORDER BY id DESC
+	`
+
+	return r.queryDeposits(ctx, query, userID)
+}
+
+// Confirm adds a confirmation to every pending deposit and returns the ones
+// that now have enough and were completed
+func (r *DepositRepository) Confirm(ctx context.Context) ([]models.Deposit, error) {
+	query := `
+		WITH confirmed AS (
+			UPDATE deposits
+			SET confirmations = confirmations + 1,
+				status = CASE WHEN confirmations + 1 >= required_confirmations THEN 'completed' ELSE status END,
+				completed_at = CASE WHEN confirmations + 1 >= required_confirmations THEN NOW() END
+			WHERE status = 'pending'
+			RETURNING ` + depositColumns + `
+		)
+		SELECT ` + depositColumns + `
+		FROM confirmed
+		WHERE status = 'completed'
+		ORDER BY id ASC
+	`
+
+	return r.queryDeposits(ctx, query)
+}
+
+func (r *DepositRepository) queryDeposits(ctx context.Context, query string, args ...any) ([]models.Deposit, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deposits: %w", err)
+	}
+	defer rows.Close()
+
+	deposits := []models.Deposit{}
+	for rows.Next() {
+		var deposit models.Deposit
+		err := rows.Scan(
+			&deposit.ID,
+			&deposit.UserID,
+			&deposit.Asset,
+			&deposit.Amount,
+			&deposit.Status,
+			&deposit.Confirmations,
+			&deposit.RequiredConfirmations,
+			&deposit.CreatedAt,
+			&deposit.CompletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan deposit: %w", err)
+		}
+		deposits = append(deposits, deposit)
+	}
+
+	return deposits, rows.Err()
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"crypto-orderbook/internal/models"
+	"fmt"
+)
+
+type FeeRepository struct {
+	db DBTX
+}
+
+func NewFeeRepository(db DBTX) *FeeRepository {
+	return &FeeRepository{db: db}
+}
+
+// GetTiers retrieves the fee schedule, lowest tier first
+func (r *FeeRepository) GetTiers(ctx context.Context) ([]models.FeeTier, error) {
+	query := `
+		SELECT tier, min_volume, maker_discount, taker_discount
+		FROM fee_tiers
+		ORDER BY min_volume
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fee tiers: %w", err)
+	}
+	defer rows.Close()
+
+	tiers := []models.FeeTier{}
+	for rows.Next() {
+		var tier models.FeeTier
+		err := rows.Scan(&tier.Tier, &tier.MinVolume, &tier.MakerDiscount, &tier.TakerDiscount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan fee tier: %w", err)
+		}
+		tiers = append(tiers, tier)
+	}
+
+	return tiers, rows.Err()
+}
+
+// GetUserTiers retrieves the current fee tier of each of the users by id
+func (r *FeeRepository) GetUserTiers(ctx context.Context, userIDs []int64) (map[int64]models.FeeTier, error) {
+	query := `
+		SELECT u.id, t.tier, t.min_volume, t.maker_discount, t.taker_discount
+		FROM users u
+		JOIN fee_tiers t ON t.tier = u.fee_tier
+		WHERE u.id = ANY($1)
+	`
+
+	rows, err := r.db.Query(ctx, query, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user fee tiers: %w", err)
+	}
+	defer rows.Close()
+
+	tiers := make(map[int64]models.FeeTier)
+	for rows.Next() {
+		var userID int64
+		var tier models.FeeTier
+		err := rows.Scan(&userID, &tier.Tier, &tier.MinVolume, &tier.MakerDiscount, &tier.TakerDiscount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user fee tier: %w", err)
+		}
+		tiers[userID] = tier
+	}
+
+	return tiers, rows.Err()
+}
+
+// GetUserVolume retrieves the fee tier and 30-day volume of a user as last
+// recomputed
+func (r *FeeRepository) GetUserVolume(ctx context.Context, userID int64) (*models.FeeSchedule, error) {
+	query := `
+		SELECT t.tier, t.min_volume, t.maker_discount, t.taker_discount, u.volume_30d, u.volume_updated_at
+		FROM users u
+		JOIN fee_tiers t ON t.tier = u.fee_tier
+		WHERE u.id = $1
+	`
+
+	schedule := &models.FeeSchedule{}
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&schedule.Tier.Tier,
+		&schedule.Tier.MinVolume,
+		&schedule.Tier.MakerDiscount,
+		&schedule.Tier.TakerDiscount,
+		&schedule.Volume30d,
+		&schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user volume: %w", err)
+	}
+
+	return schedule, nil
+}
+
+// RecomputeTiers sets the 30-day volume of every user, valued in
+// volumeAsset, and the highest fee tier it reaches. Markets quoted in
+// another asset are converted at the last price of <quote>-<volumeAsset>;
+// trades that cannot be converted do not count.
+func (r *FeeRepository) RecomputeTiers(ctx context.Context, volumeAsset string) (int64, error) {
+	query := `
+		WITH last_prices AS (
+			SELECT DISTINCT ON (symbol) symbol, price
+			FROM trades
+			ORDER BY symbol, created_at DESC, id DESC
+		),
+		volumes AS (
+			SELECT side.user_id,
+				SUM(t.price * t.amount * CASE WHEN m.quote_asset = $1 THEN 1 ELSE p.price END) AS volume
+			FROM trades t
+			JOIN markets m ON m.symbol = t.symbol
+			LEFT JOIN last_prices p ON p.symbol = m.quote_asset || '-' || $1
+			CROSS JOIN LATERAL (VALUES (t.maker_user_id), (t.taker_user_id)) AS side(user_id)
+			WHERE t.created_at > NOW() - INTERVAL '30 days'
+			GROUP BY side.user_id
+		),
+		user_volumes AS (
+			SELECT u.id, ROUND(COALESCE(v.volume, 0), 8) AS volume
+			FROM users u
+			LEFT JOIN volumes v ON v.user_id = u.id
+		)
+		UPDATE users
+		SET volume_30d = uv.volume,
+			fee_tier = COALESCE((
+				SELECT tier FROM fee_tiers
+				WHERE min_volume <= uv.volume
+				ORDER BY min_volume DESC
+				LIMIT 1
+			), 0),
+			volume_updated_at = NOW()
+		FROM user_volumes uv
+		WHERE users.id = uv.id
+	`
+
+	result, err := r.db.Exec(ctx, query, volumeAsset)
+	if err != nil {
+		return 0, fmt.Errorf("failed to recompute fee tiers: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"crypto-orderbook/internal/models"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrMarketNotFound = errors.New("market not found")
+
+type MarketRepository struct {
+	db DBTX
+}
+
+func NewMarketRepository(db DBTX) *MarketRepository {
+	return &MarketRepository{db: db}
+}
+
+// GetAll retrieves every market, tradable or not
+func (r *MarketRepository) GetAll(ctx context.Context) ([]models.Market, error) {
+	query := `
+		SELECT symbol, base_asset, quote_asset, status, tick_size, lot_size, min_quantity, min_notional, max_notional, maker_fee_rate, taker_fee_rate, created_at
+		FROM markets
+		ORDER BY symbol
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get markets: %w", err)
+	}
+	defer rows.Close()
+
+	markets := []models.Market{}
+	for rows.Next() {
+		var market models.Market
+		err := rows.Scan(
+			&market.Symbol,
+			&market.BaseAsset,
+			&market.QuoteAsset,
+			&market.Status,
+			&market.TickSize,
+			&market.LotSize,
+			&market.MinQuantity,
+			&market.MinNotional,
+			&market.MaxNotional,
+			&market.MakerFeeRate,
+			&market.TakerFeeRate,
+			&market.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan market: %w", err)
+		}
+		markets = append(markets, market)
+	}
+
+	return markets, nil
+}
+
+// GetBySymbol retrieves a market by its symbol
+func (r *MarketRepository) GetBySymbol(ctx context.Context, symbol string) (*models.Market, error) {
+	query := `
+		SELECT symbol, base_asset, quote_asset, status, tick_size, lot_size, min_quantity, min_notional, max_notional, maker_fee_rate, taker_fee_rate, created_at
+		FROM markets
+		WHERE symbol = $1
+	`
+
+	market := &models.Market{}
+	err := r.db.QueryRow(ctx, query, symbol).Scan(
+		&market.Symbol,
+		&market.BaseAsset,
+		&market.QuoteAsset,
+		&market.Status,
+		&market.TickSize,
+		&market.LotSize,
+		&market.MinQuantity,
+		&market.MinNotional,
+		&market.MaxNotional,
+		&market.MakerFeeRate,
+		&market.TakerFeeRate,
+		&market.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMarketNotFound
+		}
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+
+	return market, nil
+}
+
+// AssetExists reports whether an asset is traded in any market
+func (r *MarketRepository) AssetExists(ctx context.Context, asset string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM markets WHERE base_asset = $1 OR quote_asset = $1)`
+
+	var exists bool
+	err := r.db.QueryRow(ctx, query, asset).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check asset existence: %w", err)
+	}
+
+	return exists, nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"crypto-orderbook/internal/models"
+	"fmt"
+)
+
+type OrderEventRepository struct {
+	db DBTX
+}
+
+func NewOrderEventRepository(db DBTX) *OrderEventRepository {
+	return &OrderEventRepository{db: db}
+}
+
+// Create records an event in the history of an order
+func (r *OrderEventRepository) Create(ctx context.Context, event *models.OrderEvent) error {
+	query := `
+		INSERT INTO order_events (order_id, user_id, event_type, data, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRow(ctx, query, event.OrderID, event.UserID, event.EventType, event.Data).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create order event: %w", err)
+	}
+
+	return nil
+}
+
+// GetByOrderID retrieves the history of one of the user's orders, oldest
+// first
+func (r *OrderEventRepository) GetByOrderID(ctx context.Context, orderID, userID int64) ([]models.OrderEvent, error) {
+	query := `
+		SELECT id, order_id, user_id, event_type, data, created_at
+		FROM order_events
+		WHERE order_id = $1 AND user_id = $2
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := r.db.Query(ctx, query, orderID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.OrderEvent{}
+	for rows.Next() {
+		var event models.OrderEvent
+		if err := rows.Scan(&event.ID, &event.OrderID, &event.UserID, &event.EventType, &event.Data, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan order event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
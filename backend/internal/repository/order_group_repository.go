@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"crypto-orderbook/internal/models"
+	"fmt"
+)
+
+type OrderGroupRepository struct {
+	db DBTX
+}
+
+func NewOrderGroupRepository(db DBTX) *OrderGroupRepository {
+	return &OrderGroupRepository{db: db}
+}
+
+// Create creates a new order group
+func (r *OrderGroupRepository) Create(ctx context.Context, group *models.OrderGroup) error {
+	query := `
+		INSERT INTO order_groups (user_id, symbol, group_type, created_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRow(ctx, query, group.UserID, group.Symbol, group.GroupType).Scan(&group.ID, &group.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create order group: %w", err)
+	}
+
+	return nil
+}
@@ -2,35 +2,129 @@ package repository
 
 import (
 	"context"
+	"crypto-orderbook/internal/decimal"
 	"crypto-orderbook/internal/models"
+	"errors"
 	"fmt"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 )
 
+// orderColumns is the select list expected by scanOrder
+const orderColumns = `o.id, o.user_id, u.username, o.symbol, o.order_type, o.order_kind, o.price, o.trigger_price,
+	o.trail_offset, COALESCE(o.trail_type, ''), o.amount,
+	o.filled_amount, o.remaining_amount, o.avg_fill_price, o.status, o.time_in_force, o.expires_at,
+	o.display_amount, o.visible_amount, o.post_only, o.reduce_only, o.group_id, COALESCE(o.group_role, ''),
+	COALESCE(o.session_id, ''), o.stp_mode, o.locked_amount, o.created_at`
+
+var (
+	ErrOrderNotFound = errors.New("order not found")
+	ErrOrderNotOpen  = errors.New("order is no longer open")
+)
+
+// CancelFilter narrows down DeleteAll; an empty field matches every order
+type CancelFilter struct {
+	Side      string // "buy" or "sell"
+	Symbol    string
+	SessionID string
+}
+
 type OrderRepository struct {
-	db *pgxpool.Pool
+	db DBTX
 }
 
-func NewOrderRepository(db *pgxpool.Pool) *OrderRepository {
+func NewOrderRepository(db DBTX) *OrderRepository {
 	return &OrderRepository{db: db}
 }
 
+func scanOrder(row pgx.Row, order *models.Order) error {
+	return row.Scan(
+		&order.ID,
+		&order.UserID,
+		&order.Username,
+		&order.Symbol,
+		&order.OrderType,
+		&order.OrderKind,
+		&order.Price,
+		&order.TriggerPrice,
+		&order.TrailOffset,
+		&order.TrailType,
+		&order.Amount,
+		&order.FilledAmount,
+		&order.RemainingAmount,
+		&order.AvgFillPrice,
+		&order.Status,
+		&order.TimeInForce,
+		&order.ExpiresAt,
+		&order.DisplayAmount,
+		&order.VisibleAmount,
+		&order.PostOnly,
+		&order.ReduceOnly,
+		&order.GroupID,
+		&order.GroupRole,
+		&order.SessionID,
+		&order.STPMode,
+		&order.LockedAmount,
+		&order.CreatedAt,
+	)
+}
+
+func (r *OrderRepository) queryOrders(ctx context.Context, query string, args ...any) ([]models.Order, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		var order models.Order
+		if err := scanOrder(rows, &order); err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, rows.Err()
+}
+
 // Create creates a new order
 func (r *OrderRepository) Create(ctx context.Context, order *models.Order) error {
 	query := `
-		INSERT INTO orders (user_id, order_type, price, amount, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, NOW())
-		RETURNING id, created_at
+		INSERT INTO orders (
+			user_id, symbol, order_type, order_kind, price, trigger_price, trail_offset, trail_type,
+			amount, filled_amount, remaining_amount, status, time_in_force, expires_at, display_amount,
+			visible_amount, post_only, reduce_only, group_id, group_role, session_id, stp_mode, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21,
+			COALESCE($22, (SELECT default_stp_mode FROM users WHERE id = $1)), NOW())
+		RETURNING id, stp_mode, created_at
 	`
 
 	err := r.db.QueryRow(ctx, query,
 		order.UserID,
+		order.Symbol,
 		order.OrderType,
-		order.Price,
+		order.OrderKind,
+		nullIfZero(order.Price),
+		nullIfZero(order.TriggerPrice),
+		nullIfZero(order.TrailOffset),
+		nullIfEmpty(order.TrailType),
 		order.Amount,
+		order.FilledAmount,
+		order.RemainingAmount,
 		order.Status,
-	).Scan(&order.ID, &order.CreatedAt)
+		order.TimeInForce,
+		order.ExpiresAt,
+		nullIfZero(order.DisplayAmount),
+		nullIfZero(order.VisibleAmount),
+		order.PostOnly,
+		order.ReduceOnly,
+		order.GroupID,
+		nullIfEmpty(order.GroupRole),
+		nullIfEmpty(order.SessionID),
+		nullIfEmpty(order.STPMode),
+	).Scan(&order.ID, &order.STPMode, &order.CreatedAt)
 
 	if err != nil {
 		return fmt.Errorf("failed to create order: %w", err)
@@ -39,147 +133,361 @@ func (r *OrderRepository) Create(ctx context.Context, order *models.Order) error
 	return nil
 }
 
-// GetAll retrieves all active orders
+// GetAll retrieves all open orders
 func (r *OrderRepository) GetAll(ctx context.Context) ([]models.Order, error) {
 	query := `
-		SELECT o.id, o.user_id, u.username, o.order_type, o.price, o.amount, o.status, o.created_at
+		SELECT ` + orderColumns + `
 		FROM orders o
 		JOIN users u ON o.user_id = u.id
-		WHERE o.status = 'active'
+		WHERE o.status IN ('active', 'partially_filled')
 		ORDER BY o.created_at DESC
 	`
 
-	rows, err := r.db.Query(ctx, query)
+	orders, err := r.queryOrders(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders: %w", err)
 	}
-	defer rows.Close()
-
-	var orders []models.Order
-	for rows.Next() {
-		var order models.Order
-		err := rows.Scan(
-			&order.ID,
-			&order.UserID,
-			&order.Username,
-			&order.OrderType,
-			&order.Price,
-			&order.Amount,
-			&order.Status,
-			&order.CreatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan order: %w", err)
-		}
-		orders = append(orders, order)
-	}
 
 	return orders, nil
 }
 
-// GetOrderBook retrieves buy and sell orders separately
-func (r *OrderRepository) GetOrderBook(ctx context.Context) (*models.OrderBook, error) {
+// GetOrderBook retrieves buy and sell orders of a market separately, as
+// other traders may see them
+func (r *OrderRepository) GetOrderBook(ctx context.Context, symbol string) (*models.OrderBook, error) {
 	query := `
-		SELECT o.id, o.user_id, u.username, o.order_type, o.price, o.amount, o.status, o.created_at
+		SELECT ` + orderColumns + `
 		FROM orders o
 		JOIN users u ON o.user_id = u.id
-		WHERE o.status = 'active'
-		ORDER BY 
+		WHERE o.symbol = $1 AND o.status IN ('active', 'partially_filled')
+		ORDER BY
 			CASE WHEN o.order_type = 'buy' THEN o.price END DESC,
 			CASE WHEN o.order_type = 'sell' THEN o.price END ASC,
-			o.created_at ASC
+			o.priority ASC
 	`
 
-	rows, err := r.db.Query(ctx, query)
+	orders, err := r.queryOrders(ctx, query, symbol)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get order book: %w", err)
 	}
-	defer rows.Close()
 
 	orderBook := &models.OrderBook{
+		Symbol:     symbol,
 		BuyOrders:  []models.Order{},
 		SellOrders: []models.Order{},
 	}
 
-	for rows.Next() {
-		var order models.Order
-		err := rows.Scan(
-			&order.ID,
-			&order.UserID,
-			&order.Username,
-			&order.OrderType,
-			&order.Price,
-			&order.Amount,
-			&order.Status,
-			&order.CreatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan order: %w", err)
-		}
-
+	for _, order := range orders {
 		if order.OrderType == "buy" {
-			orderBook.BuyOrders = append(orderBook.BuyOrders, order)
+			orderBook.BuyOrders = append(orderBook.BuyOrders, order.PublicView())
 		} else {
-			orderBook.SellOrders = append(orderBook.SellOrders, order)
+			orderBook.SellOrders = append(orderBook.SellOrders, order.PublicView())
 		}
 	}
 
 	return orderBook, nil
 }
 
+// GetOpenOrders retrieves every resting order and every stop order waiting
+// for its trigger in time priority so the matching engine can rebuild its
+// books on startup
+func (r *OrderRepository) GetOpenOrders(ctx context.Context) ([]models.Order, error) {
+	query := `
+		SELECT ` + orderColumns + `
+		FROM orders o
+		JOIN users u ON o.user_id = u.id
+		WHERE o.status IN ('pending_trigger', 'active', 'partially_filled')
+		ORDER BY o.priority ASC
+	`
+
+	orders, err := r.queryOrders(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+// GetSessions returns the cancel-on-disconnect sessions unfinished orders
+// were placed with, as the user of each session by session id
+func (r *OrderRepository) GetSessions(ctx context.Context) (map[string]int64, error) {
+	query := `
+		SELECT DISTINCT session_id, user_id
+		FROM orders
+		WHERE session_id IS NOT NULL
+			AND status IN ('pending_activation', 'pending_trigger', 'active', 'partially_filled')
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make(map[string]int64)
+	for rows.Next() {
+		var sessionID string
+		var userID int64
+		if err := rows.Scan(&sessionID, &userID); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions[sessionID] = userID
+	}
+
+	return sessions, rows.Err()
+}
+
 // GetByUserID retrieves all orders for a specific user
 func (r *OrderRepository) GetByUserID(ctx context.Context, userID int64) ([]models.Order, error) {
 	query := `
-		SELECT o.id, o.user_id, u.username, o.order_type, o.price, o.amount, o.status, o.created_at
+		SELECT ` + orderColumns + `
 		FROM orders o
 		JOIN users u ON o.user_id = u.id
 		WHERE o.user_id = $1
 		ORDER BY o.created_at DESC
 	`
 
-	rows, err := r.db.Query(ctx, query, userID)
+	orders, err := r.queryOrders(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user orders: %w", err)
 	}
-	defer rows.Close()
 
-	var orders []models.Order
-	for rows.Next() {
-		var order models.Order
-		err := rows.Scan(
-			&order.ID,
-			&order.UserID,
-			&order.Username,
-			&order.OrderType,
-			&order.Price,
-			&order.Amount,
-			&order.Status,
-			&order.CreatedAt,
+	return orders, nil
+}
+
+// cancelOrders soft deletes the unfinished orders matching where, which may
+// refer to the orders table as o, and returns them
+func (r *OrderRepository) cancelOrders(ctx context.Context, where string, args ...any) ([]models.Order, error) {
+	query := `
+		UPDATE orders o
+		SET status = 'cancelled'
+		FROM users u
+		WHERE ` + where + `
+			AND o.status IN ('pending_activation', 'pending_trigger', 'active', 'partially_filled')
+			AND u.id = o.user_id
+		RETURNING ` + orderColumns
+
+	return r.queryOrders(ctx, query, args...)
+}
+
+// Delete cancels an open order (soft delete by updating status) and returns
+// it. It fails with ErrOrderNotFound if the user has no such order and with
+// ErrOrderNotOpen if the order already finished.
+func (r *OrderRepository) Delete(ctx context.Context, orderID int64, userID int64) (*models.Order, error) {
+	orders, err := r.cancelOrders(ctx, "o.id = $1 AND o.user_id = $2", orderID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete order: %w", err)
+	}
+	if len(orders) == 1 {
+		return &orders[0], nil
+	}
+
+	var exists bool
+	err = r.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM orders WHERE id = $1 AND user_id = $2)`, orderID, userID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete order: %w", err)
+	}
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+
+	return nil, ErrOrderNotOpen
+}
+
+// DeleteAll cancels every unfinished order of the user that matches filter
+// and returns them. Bracket legs waiting for their entry are left to be
+// settled with the entry.
+func (r *OrderRepository) DeleteAll(ctx context.Context, userID int64, filter CancelFilter) ([]models.Order, error) {
+	where := `o.user_id = $1 AND ($2 = '' OR o.order_type = $2) AND ($3 = '' OR o.symbol = $3)
+		AND ($4 = '' OR o.session_id = $4) AND o.status <> 'pending_activation'`
+
+	orders, err := r.cancelOrders(ctx, where, userID, filter.Side, filter.Symbol, filter.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+// GetByID retrieves one of the user's orders
+func (r *OrderRepository) GetByID(ctx context.Context, orderID, userID int64) (*models.Order, error) {
+	query := `
+		SELECT ` + orderColumns + `
+		FROM orders o
+		JOIN users u ON o.user_id = u.id
+		WHERE o.id = $1 AND o.user_id = $2
+	`
+
+	order := &models.Order{}
+	err := scanOrder(r.db.QueryRow(ctx, query, orderID, userID), order)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	return order, nil
+}
+
+// GetByGroupID retrieves the orders of a group in the order they were placed
+func (r *OrderRepository) GetByGroupID(ctx context.Context, groupID int64) ([]models.Order, error) {
+	query := `
+		SELECT ` + orderColumns + `
+		FROM orders o
+		JOIN users u ON o.user_id = u.id
+		WHERE o.group_id = $1
+		ORDER BY o.id ASC
+	`
+
+	orders, err := r.queryOrders(ctx, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+// CancelSiblings cancels every order of a group other than orderID that has
+// not finished yet and returns them. Run inside the transaction that filled
+// or cancelled orderID, the group can never end up with two live legs.
+func (r *OrderRepository) CancelSiblings(ctx context.Context, groupID, orderID int64) ([]models.Order, error) {
+	orders, err := r.cancelOrders(ctx, "o.group_id = $1 AND o.id <> $2", groupID, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel group orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+// ActivateLegs sizes the waiting legs of a bracket group to amount and makes
+// them live: stop legs start waiting for their trigger, the others become
+// active. It returns the activated legs in the order they were placed.
+func (r *OrderRepository) ActivateLegs(ctx context.Context, groupID int64, amount decimal.Decimal) ([]models.Order, error) {
+	query := `
+		WITH activated AS (
+			UPDATE orders
+			SET status = CASE WHEN order_kind IN ('stop', 'stop_limit', 'trailing_stop') THEN 'pending_trigger' ELSE 'active' END,
+				amount = $2,
+				remaining_amount = $2,
+				visible_amount = CASE WHEN display_amount IS NULL THEN NULL ELSE LEAST(display_amount, $2) END
+			WHERE group_id = $1 AND status = 'pending_activation'
+			RETURNING *
 		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan order: %w", err)
-		}
-		orders = append(orders, order)
+		SELECT ` + orderColumns + `
+		FROM activated o
+		JOIN users u ON o.user_id = u.id
+		ORDER BY o.id ASC
+	`
+
+	orders, err := r.queryOrders(ctx, query, groupID, amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to activate group orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+// Expire marks open orders as expired and returns the ones that were still
+// open
+func (r *OrderRepository) Expire(ctx context.Context, orderIDs []int64) ([]models.Order, error) {
+	query := `
+		UPDATE orders o
+		SET status = 'expired'
+		FROM users u
+		WHERE o.id = ANY($1) AND o.status IN ('pending_trigger', 'active', 'partially_filled')
+			AND u.id = o.user_id
+		RETURNING ` + orderColumns
+
+	orders, err := r.queryOrders(ctx, query, orderIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire orders: %w", err)
 	}
 
 	return orders, nil
 }
 
-// Delete deletes an order (soft delete by updating status)
-func (r *OrderRepository) Delete(ctx context.Context, orderID int64, userID int64) error {
+// UpdateExecution stores the amounts, average fill price, visible slice,
+// status and reserved funds of an order after matching. Self-trade
+// prevention may have reduced its total amount.
+func (r *OrderRepository) UpdateExecution(ctx context.Context, order *models.Order) error {
 	query := `
 		UPDATE orders
-		SET status = 'cancelled'
-		WHERE id = $1 AND user_id = $2 AND status = 'active'
+		SET amount = $2, filled_amount = $3, remaining_amount = $4, avg_fill_price = $5, visible_amount = $6, status = $7,
+			locked_amount = $8
+		WHERE id = $1
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		order.ID,
+		order.Amount,
+		order.FilledAmount,
+		order.RemainingAmount,
+		order.AvgFillPrice,
+		nullIfZero(order.VisibleAmount),
+		order.Status,
+		order.LockedAmount,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update order %d: %w", order.ID, err)
+	}
+
+	return nil
+}
+
+// Amend stores a new price and amount for an order that is still open or
+// waiting for its trigger. With requeue it also loses its place in the queue.
+func (r *OrderRepository) Amend(ctx context.Context, order *models.Order, requeue bool) error {
+	query := `
+		UPDATE orders
+		SET price = $2, amount = $3, remaining_amount = $4, visible_amount = $5,
+			priority = CASE WHEN $6 THEN nextval('order_priority_seq') ELSE priority END
+		WHERE id = $1 AND status IN ('pending_trigger', 'active', 'partially_filled')
 	`
 
-	result, err := r.db.Exec(ctx, query, orderID, userID)
+	tag, err := r.db.Exec(ctx, query,
+		order.ID,
+		nullIfZero(order.Price),
+		order.Amount,
+		order.RemainingAmount,
+		nullIfZero(order.VisibleAmount),
+		requeue,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to amend order %d: %w", order.ID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrOrderNotOpen
+	}
+
+	return nil
+}
+
+// UpdateTrigger stores the current trigger price of a trailing stop
+func (r *OrderRepository) UpdateTrigger(ctx context.Context, order *models.Order) error {
+	_, err := r.db.Exec(ctx, `UPDATE orders SET trigger_price = $2 WHERE id = $1`, order.ID, order.TriggerPrice)
 	if err != nil {
-		return fmt.Errorf("failed to delete order: %w", err)
+		return fmt.Errorf("failed to update trigger of order %d: %w", order.ID, err)
 	}
 
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("order not found or already cancelled")
+	return nil
+}
+
+// UpdateLocked stores the funds reserved for an order
+func (r *OrderRepository) UpdateLocked(ctx context.Context, order *models.Order) error {
+	_, err := r.db.Exec(ctx, `UPDATE orders SET locked_amount = $2 WHERE id = $1`, order.ID, order.LockedAmount)
+	if err != nil {
+		return fmt.Errorf("failed to update order %d: %w", order.ID, err)
+	}
+
+	return nil
+}
+
+// Requeue moves an order behind every other order in time priority, as when
+// an iceberg order shows a new slice
+func (r *OrderRepository) Requeue(ctx context.Context, orderID int64) error {
+	_, err := r.db.Exec(ctx, `UPDATE orders SET priority = nextval('order_priority_seq') WHERE id = $1`, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to requeue order %d: %w", orderID, err)
 	}
 
 	return nil
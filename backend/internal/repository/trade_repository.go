@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"crypto-orderbook/internal/decimal"
+	"crypto-orderbook/internal/models"
+	"fmt"
+)
+
+type TradeRepository struct {
+	db DBTX
+}
+
+func NewTradeRepository(db DBTX) *TradeRepository {
+	return &TradeRepository{db: db}
+}
+
+// Create records an execution
+func (r *TradeRepository) Create(ctx context.Context, trade *models.Trade) error {
+	query := `
+		INSERT INTO trades (
+			symbol, maker_order_id, taker_order_id, maker_user_id, taker_user_id, price, amount, aggressor_side,
+			maker_fee, maker_fee_asset, taker_fee, taker_fee_asset, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW())
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRow(ctx, query,
+		trade.Symbol,
+		trade.MakerOrderID,
+		trade.TakerOrderID,
+		trade.MakerUserID,
+		trade.TakerUserID,
+		trade.Price,
+		trade.Amount,
+		trade.AggressorSide,
+		trade.MakerFee,
+		trade.MakerFeeAsset,
+		trade.TakerFee,
+		trade.TakerFeeAsset,
+	).Scan(&trade.ID, &trade.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create trade: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecent retrieves the most recent trades, newest first. An empty symbol
+// returns trades from every market.
+func (r *TradeRepository) GetRecent(ctx context.Context, symbol string, limit int) ([]models.Trade, error) {
+	query := `
+		SELECT id, symbol, maker_order_id, taker_order_id, maker_user_id, taker_user_id, price, amount, aggressor_side,
+			maker_fee, maker_fee_asset, taker_fee, taker_fee_asset, created_at
+		FROM trades
+		WHERE $1 = '' OR symbol = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, symbol, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trades: %w", err)
+	}
+	defer rows.Close()
+
+	trades := []models.Trade{}
+	for rows.Next() {
+		var trade models.Trade
+		err := rows.Scan(
+			&trade.ID,
+			&trade.Symbol,
+			&trade.MakerOrderID,
+			&trade.TakerOrderID,
+			&trade.MakerUserID,
+			&trade.TakerUserID,
+			&trade.Price,
+			&trade.Amount,
+			&trade.AggressorSide,
+			&trade.MakerFee,
+			&trade.MakerFeeAsset,
+			&trade.TakerFee,
+			&trade.TakerFeeAsset,
+			&trade.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trade: %w", err)
+		}
+		trades = append(trades, trade)
+	}
+
+	return trades, rows.Err()
+}
+
+// NetPosition returns how much of a market's base asset the user has bought
+// minus how much they have sold
+func (r *TradeRepository) NetPosition(ctx context.Context, userID int64, symbol string) (decimal.Decimal, error) {
+	query := `
+		SELECT COALESCE(SUM(
+			CASE WHEN taker_user_id = $1 THEN
+				CASE WHEN aggressor_side = 'buy' THEN amount ELSE -amount END
+			ELSE 0 END
+			+
+			CASE WHEN maker_user_id = $1 THEN
+				CASE WHEN aggressor_side = 'sell' THEN amount ELSE -amount END
+			ELSE 0 END
+		), 0)
+		FROM trades
+		WHERE symbol = $2 AND (maker_user_id = $1 OR taker_user_id = $1)
+	`
+
+	var position decimal.Decimal
+	if err := r.db.QueryRow(ctx, query, userID, symbol).Scan(&position); err != nil {
+		return decimal.Zero, fmt.Errorf("failed to get net position: %w", err)
+	}
+
+	return position, nil
+}
+
+// LastPrices returns the price of the most recent trade in every market that
+// has traded
+func (r *TradeRepository) LastPrices(ctx context.Context) (map[string]decimal.Decimal, error) {
+	query := `
+		SELECT DISTINCT ON (symbol) symbol, price
+		FROM trades
+		ORDER BY symbol, created_at DESC, id DESC
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last prices: %w", err)
+	}
+	defer rows.Close()
+
+	prices := make(map[string]decimal.Decimal)
+	for rows.Next() {
+		var symbol string
+		var price decimal.Decimal
+		if err := rows.Scan(&symbol, &price); err != nil {
+			return nil, fmt.Errorf("failed to scan last price: %w", err)
+		}
+		prices[symbol] = price
+	}
+
+	return prices, rows.Err()
+}
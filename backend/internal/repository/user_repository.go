@@ -7,14 +7,13 @@ import (
 	"fmt"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type UserRepository struct {
-	db *pgxpool.Pool
+	db DBTX
 }
 
-func NewUserRepository(db *pgxpool.Pool) *UserRepository {
+func NewUserRepository(db DBTX) *UserRepository {
 	return &UserRepository{db: db}
 }
 
@@ -23,11 +22,11 @@ func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
 		INSERT INTO users (email, username, password_hash, created_at)
 		VALUES ($1, $2, $3, NOW())
-		RETURNING id, created_at
+		RETURNING id, default_stp_mode, created_at
 	`
 
 	err := r.db.QueryRow(ctx, query, user.Email, user.Username, user.PasswordHash).
-		Scan(&user.ID, &user.CreatedAt)
+		Scan(&user.ID, &user.DefaultSTPMode, &user.CreatedAt)
 
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
@@ -39,7 +38,7 @@ func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 // GetByEmail retrieves a user by email
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
-		SELECT id, email, username, password_hash, created_at
+		SELECT id, email, username, password_hash, default_stp_mode, created_at
 		FROM users
 		WHERE email = $1
 	`
@@ -50,6 +49,7 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.
 		&user.Email,
 		&user.Username,
 		&user.PasswordHash,
+		&user.DefaultSTPMode,
 		&user.CreatedAt,
 	)
 
@@ -66,7 +66,7 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.
 // GetByID retrieves a user by ID
 func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
 	query := `
-		SELECT id, email, username, password_hash, created_at
+		SELECT id, email, username, password_hash, default_stp_mode, created_at
 		FROM users
 		WHERE id = $1
 	`
@@ -77,6 +77,7 @@ func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, e
 		&user.Email,
 		&user.Username,
 		&user.PasswordHash,
+		&user.DefaultSTPMode,
 		&user.CreatedAt,
 	)
 
@@ -93,7 +94,7 @@ func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, e
 // GetByUsername retrieves a user by username
 func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	query := `
-		SELECT id, email, username, password_hash, created_at
+		SELECT id, email, username, password_hash, default_stp_mode, created_at
 		FROM users
 		WHERE username = $1
 	`
@@ -104,6 +105,7 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*m
 		&user.Email,
 		&user.Username,
 		&user.PasswordHash,
+		&user.DefaultSTPMode,
 		&user.CreatedAt,
 	)
 
@@ -117,6 +119,36 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*m
 	return user, nil
 }
 
+// UpdateDefaultSTPMode sets the self-trade prevention mode used for the
+// user's orders that don't set one
+func (r *UserRepository) UpdateDefaultSTPMode(ctx context.Context, id int64, mode string) (*models.User, error) {
+	query := `
+		UPDATE users
+		SET default_stp_mode = $2
+		WHERE id = $1
+		RETURNING id, email, username, password_hash, default_stp_mode, created_at
+	`
+
+	user := &models.User{}
+	err := r.db.QueryRow(ctx, query, id, mode).Scan(
+		&user.ID,
+		&user.Email,
+		&user.Username,
+		&user.PasswordHash,
+		&user.DefaultSTPMode,
+		&user.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return user, nil
+}
+
 // EmailExists checks if an email already exists
 func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)`
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"crypto-orderbook/internal/models"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrWithdrawalNotFound = errors.New("withdrawal not found")
+	ErrWithdrawalStatus   = errors.New("withdrawal cannot move to that status")
+)
+
+const withdrawalColumns = `id, user_id, asset, amount, address, status, created_at, updated_at`
+
+type WithdrawalRepository struct {
+	db DBTX
+}
+
+func NewWithdrawalRepository(db DBTX) *WithdrawalRepository {
+	return &WithdrawalRepository{db: db}
+}
+
+// Create records a new pending withdrawal
+func (r *WithdrawalRepository) Create(ctx context.Context, withdrawal *models.Withdrawal) error {
+	query := `
+		INSERT INTO withdrawals (user_id, asset, amount, address, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, 'pending', NOW(), NOW())
+		RETURNING id, status, created_at, updated_at
+	`
+
+	err := r.db.QueryRow(ctx, query, withdrawal.UserID, withdrawal.Asset, withdrawal.Amount, withdrawal.Address).
+		Scan(&withdrawal.ID, &withdrawal.Status, &withdrawal.CreatedAt, &withdrawal.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create withdrawal: %w", err)
+	}
+
+	return nil
+}
+
+// GetByUserID retrieves the withdrawals of a user, newest first
+func (r *WithdrawalRepository) GetByUserID(ctx context.Context, userID int64) ([]models.Withdrawal, error) {
+	query := `
+		SELECT ` + withdrawalColumns + `
+		FROM withdrawals
+		WHERE user_id = $1
+		ORDER BY id DESC
+	`
+
+	return r.queryWithdrawals(ctx, query, userID)
+}
+
+// GetByStatus retrieves the withdrawals of every user, optionally only those
+// in one status, oldest first
+func (r *WithdrawalRepository) GetByStatus(ctx context.Context, status string) ([]models.Withdrawal, error) {
+	query := `
+		SELECT ` + withdrawalColumns + `
+		FROM withdrawals
+		WHERE $1 = '' OR status = $1
+		ORDER BY id ASC
+	`
+
+	return r.queryWithdrawals(ctx, query, status)
+}
+
+// UpdateStatus moves a withdrawal to status if it is currently in one of
+// from. It returns ErrWithdrawalNotFound or ErrWithdrawalStatus otherwise.
+func (r *WithdrawalRepository) UpdateStatus(ctx context.Context, id int64, from []string, status string) (*models.Withdrawal, error) {
+	query := `
+		UPDATE withdrawals
+		SET status = $3, updated_at = NOW()
+		WHERE id = $1 AND status = ANY($2)
+		RETURNING ` + withdrawalColumns
+
+	withdrawals, err := r.queryWithdrawals(ctx, query, id, from, status)
+	if err != nil {
+		return nil, err
+	}
+	if len(withdrawals) == 1 {
+		return &withdrawals[0], nil
+	}
+
+	var exists bool
+	err = r.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM withdrawals WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update withdrawal: %w", err)
+	}
+	if !exists {
+		return nil, ErrWithdrawalNotFound
+	}
+	return nil, ErrWithdrawalStatus
+}
+
+func (r *WithdrawalRepository) queryWithdrawals(ctx context.Context, query string, args ...any) ([]models.Withdrawal, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get withdrawals: %w", err)
+	}
+	defer rows.Close()
+
+	withdrawals := []models.Withdrawal{}
+	for rows.Next() {
+		var withdrawal models.Withdrawal
+		err := rows.Scan(
+			&withdrawal.ID,
+			&withdrawal.UserID,
+			&withdrawal.Asset,
+			&withdrawal.Amount,
+			&withdrawal.Address,
+			&withdrawal.Status,
+			&withdrawal.CreatedAt,
+			&withdrawal.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan withdrawal: %w", err)
+		}
+		withdrawals = append(withdrawals, withdrawal)
+	}
+
+	return withdrawals, rows.Err()
+}
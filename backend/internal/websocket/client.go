@@ -7,23 +7,43 @@ import (
 )
 
 const (
-	writeWait  = 10 * time.Second
-	pongWait   = 60 * time.Second
-	pingPeriod = (pongWait * 9) / 10
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 4096
+	sendBuffer     = 1024 // room for a full replay of a channel's history
 )
 
 type Client struct {
 	hub  *Hub
 	conn *websocket.Conn
 	send chan []byte
+
+	// Cancel-on-disconnect session opened or resumed for this connection
+	sessionID string
+
+	// Authenticated user, 0 for anonymous clients; subscribed channels; and
+	// whether the hub has dropped the client. All guarded by hub.mu, as a
+	// client may authenticate after connecting.
+	userID   int64
+	channels map[string]bool
+	closed   bool
 }
 
-func NewClient(hub *Hub, conn *websocket.Conn) *Client {
-	return &Client{
-		hub:  hub,
-		conn: conn,
-		send: make(chan []byte, 256),
+// NewClient creates a client subscribed to channels from the start
+func NewClient(hub *Hub, conn *websocket.Conn, userID int64, sessionID string, channels []string) *Client {
+	client := &Client{
+		hub:       hub,
+		conn:      conn,
+		send:      make(chan []byte, sendBuffer),
+		userID:    userID,
+		sessionID: sessionID,
+		channels:  make(map[string]bool),
+	}
+	for _, channel := range channels {
+		client.channels[channel] = true
 	}
+	return client
 }
 
 func (c *Client) ReadPump() {
@@ -32,6 +52,7 @@ func (c *Client) ReadPump() {
 		c.conn.Close()
 	}()
 
+	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -39,10 +60,11 @@ func (c *Client) ReadPump() {
 	})
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			break
 		}
+		c.hub.handleCommand(c, data)
 	}
 }
 
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"crypto-orderbook/internal/models"
+	"encoding/json"
+	"log"
+	"sort"
+)
+
+// depthBook is a market's aggregated book as of its latest depth update,
+// kept so that new subscribers can start from a snapshot
+type depthBook struct {
+	bids map[string]models.PriceLevel
+	asks map[string]models.PriceLevel
+}
+
+// BroadcastDepth announces a change to a market's aggregated book. Like
+// every channel message it carries the channel's next sequence number, so a
+// client that sees a gap knows it missed an update.
+func (h *Hub) BroadcastDepth(update *models.DepthUpdate) {
+	h.publish(message{channel: DepthChannel(update.Symbol), depth: update}, update.Symbol, "depth_update", map[string]interface{}{
+		"bids": update.Bids,
+		"asks": update.Asks,
+	})
+}
+
+// applyDepth brings the kept aggregated book of a market up to date. The
+// caller holds h.mu.
+func (h *Hub) applyDepth(update *models.DepthUpdate) {
+	book, ok := h.depths[update.Symbol]
+	if !ok {
+		book = &depthBook{
+			bids: make(map[string]models.PriceLevel),
+			asks: make(map[string]models.PriceLevel),
+		}
+		h.depths[update.Symbol] = book
+	}
+
+	for side, levels := range map[*map[string]models.PriceLevel][]models.PriceLevel{
+		&book.bids: update.Bids,
+		&book.asks: update.Asks,
+	} {
+		for _, lvl := range levels {
+			if lvl.Amount.IsZero() {
+				delete(*side, lvl.Price.String())
+			} else {
+				(*side)[lvl.Price.String()] = lvl
+			}
+		}
+	}
+}
+
+// depthSnapshot encodes the kept aggregated book of a market with the
+// sequence number of the latest update it reflects. Updates with higher
+// numbers follow it. The caller holds h.mu.
+func (h *Hub) depthSnapshot(symbol string) []byte {
+	snapshot := map[string]interface{}{
+		"type":     "depth_snapshot",
+		"channel":  DepthChannel(symbol),
+		"symbol":   symbol,
+		"sequence": h.stream(DepthChannel(symbol)).last(),
+		"bids":     []models.PriceLevel{},
+		"asks":     []models.PriceLevel{},
+	}
+	if book, ok := h.depths[symbol]; ok {
+		snapshot["bids"] = sortedLevels(book.bids, true)
+		snapshot["asks"] = sortedLevels(book.asks, false)
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		log.Printf("Error marshaling depth_snapshot: %v", err)
+		return nil
+	}
+	return data
+}
+
+// sortedLevels lists the levels of a side best price first
+func sortedLevels(levels map[string]models.PriceLevel, descending bool) []models.PriceLevel {
+	sorted := make([]models.PriceLevel, 0, len(levels))
+	for _, lvl := range levels {
+		sorted = append(sorted, lvl)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if descending {
+			return sorted[i].Price.GreaterThan(sorted[j].Price)
+		}
+		return sorted[i].Price.LessThan(sorted[j].Price)
+	})
+	return sorted
+}
@@ -5,22 +5,52 @@ import (
 	"encoding/json"
 	"log"
 	"sync"
+	"time"
 )
 
+// message is an event together with the channel it belongs to. A message
+// with a userID is private and only goes to that user's clients subscribed
+// to the channel.
+type message struct {
+	channel string
+	userID  int64
+	event   map[string]interface{}
+	retain  bool // kept for clients that subscribe to the channel later
+
+	depth *models.DepthUpdate // applied to the kept aggregated book
+}
+
 type Hub struct {
 	clients    map[*Client]bool
-	broadcast  chan []byte
+	broadcast  chan message
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
+
+	secret   string                // signs the tokens clients authenticate with
+	symbols  map[string]bool       // markets that have channels
+	streams  map[string]*stream    // numbering and recent history by channel
+	base     uint64                // where sequence numbers start
+	retained map[string][]byte     // latest retained message by channel
+	depths   map[string]*depthBook // aggregated book by market symbol
+
+	sessions        map[string]*session // cancel-on-disconnect sessions by id
+	onSessionClosed func(userID int64, sessionID string)
 }
 
-func NewHub() *Hub {
+func NewHub(jwtSecret string) *Hub {
 	return &Hub{
+		secret:     jwtSecret,
 		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte, 256),
+		broadcast:  make(chan message, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		symbols:    make(map[string]bool),
+		streams:    make(map[string]*stream),
+		base:       uint64(time.Now().UnixMicro()),
+		retained:   make(map[string][]byte),
+		depths:     make(map[string]*depthBook),
+		sessions:   make(map[string]*session),
 	}
 }
 
@@ -29,34 +59,104 @@ func (h *Hub) Run() {
 		select {
 		case client := <-h.register:
 			h.mu.Lock()
+			if client.closed {
+				h.mu.Unlock()
+				continue
+			}
 			h.clients[client] = true
+			if client.sessionID != "" {
+				if info := h.sessionInfo(client); info != nil {
+					client.send <- info
+				}
+			}
+			for channel := range client.channels {
+				if data := h.initialMessage(channel); data != nil {
+					h.deliver(client, data)
+				}
+			}
 			h.mu.Unlock()
 			log.Printf("Client connected. Total: %d", len(h.clients))
 
 		case client := <-h.unregister:
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
+				h.remove(client)
 			}
 			h.mu.Unlock()
 			log.Printf("Client disconnected. Total: %d", len(h.clients))
 
-		case message := <-h.broadcast:
-			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
+		case msg := <-h.broadcast:
+			h.mu.Lock()
+			if data := h.encode(msg); data != nil {
+				for client := range h.clients {
+					// Private events only go to their user, and like
+					// everything else only to clients subscribed to the channel
+					if msg.userID != 0 && client.userID != msg.userID {
+						continue
+					}
+					if !client.channels[msg.channel] {
+						continue
+					}
+					h.deliver(client, data)
 				}
 			}
-			h.mu.RUnlock()
+			h.mu.Unlock()
 		}
 	}
 }
 
+// encode numbers a channel message with the channel's next sequence number
+// and records it in the channel's history before returning it encoded.
+// Private messages are numbered per user. The caller holds h.mu.
+func (h *Hub) encode(msg message) []byte {
+	var s *stream
+	if msg.channel != "" {
+		s = h.stream(streamKey(msg.channel, msg.userID))
+		msg.event["sequence"] = s.next
+	}
+
+	data, err := json.Marshal(msg.event)
+	if err != nil {
+		log.Printf("Error marshaling %s: %v", msg.event["type"], err)
+		return nil
+	}
+
+	if s != nil {
+		s.push(data)
+		if msg.retain {
+			h.retained[msg.channel] = data
+		}
+		if msg.depth != nil {
+			h.applyDepth(msg.depth)
+		}
+	}
+	return data
+}
+
+// deliver queues data for a client, dropping the client if it cannot keep
+// up. The caller holds h.mu.
+func (h *Hub) deliver(client *Client, data []byte) {
+	select {
+	case client.send <- data:
+	default:
+		h.remove(client)
+	}
+}
+
+// remove drops a client and closes its channel; a session it belonged to
+// starts its grace period once it has no clients left. The caller holds h.mu.
+func (h *Hub) remove(client *Client) {
+	if client.closed {
+		return
+	}
+	client.closed = true
+	delete(h.clients, client)
+	close(client.send)
+	if client.sessionID != "" {
+		h.leaveSession(client)
+	}
+}
+
 // Register - public method to register a client
 func (h *Hub) Register(client *Client) {
 	h.register <- client
@@ -67,15 +167,109 @@ func (h *Hub) Unregister(client *Client) {
 	h.unregister <- client
 }
 
+// BroadcastOrder announces a new resting order. Like every order event it
+// carries the public view, so iceberg orders only show their current slice.
 func (h *Hub) BroadcastOrder(order *models.Order) {
-	message, err := json.Marshal(map[string]interface{}{
-		"type":  "new_order",
-		"order": order,
+	h.broadcastEvent(order.Symbol, "new_order", "order", order.PublicView())
+}
+
+// BroadcastOrderUpdate announces a change to an existing order, such as a fill
+func (h *Hub) BroadcastOrderUpdate(order *models.Order) {
+	h.broadcastEvent(order.Symbol, "order_update", "order", order.PublicView())
+}
+
+// BroadcastOrderCancelled tells clients to drop a cancelled order from the book
+func (h *Hub) BroadcastOrderCancelled(order *models.Order) {
+	h.broadcastEvent(order.Symbol, "order_cancelled", "order", order.PublicView())
+}
+
+// BroadcastOrdersCancelled tells clients to drop several cancelled orders of
+// one market from the book in a single message
+func (h *Hub) BroadcastOrdersCancelled(symbol string, orders []models.Order) {
+	views := make([]models.Order, len(orders))
+	for i := range orders {
+		views[i] = orders[i].PublicView()
+	}
+	h.broadcastEvent(symbol, "orders_cancelled", "orders", views)
+}
+
+// BroadcastOrderExpired tells clients to drop an expired order from the book
+func (h *Hub) BroadcastOrderExpired(order *models.Order) {
+	h.broadcastEvent(order.Symbol, "order_expired", "order", order.PublicView())
+}
+
+// BroadcastOrderAmended announces a new price or amount of an order together
+// with what changed
+func (h *Hub) BroadcastOrderAmended(order *models.Order, amendment *models.Amendment) {
+	h.publish(message{channel: BookChannel(order.Symbol)}, order.Symbol, "order_amended", map[string]interface{}{
+		"order":     order.PublicView(),
+		"amendment": amendment,
 	})
-	if err != nil {
-		log.Printf("Error marshaling order: %v", err)
-		return
+}
+
+// BroadcastTrade announces an execution without what only concerns the two
+// sides; they get their fills on their orders channel
+func (h *Hub) BroadcastTrade(trade *models.Trade) {
+	h.publish(message{channel: TradesChannel(trade.Symbol)}, trade.Symbol, "trade", map[string]interface{}{"trade": trade.PublicView()})
+}
+
+// BroadcastTicker announces a market's new last price or best bid or ask.
+// The latest ticker is also sent to clients as they subscribe.
+func (h *Hub) BroadcastTicker(ticker *models.Ticker) {
+	h.publish(message{channel: TickerChannel(ticker.Symbol), retain: true}, ticker.Symbol, "ticker", map[string]interface{}{"ticker": ticker})
+}
+
+// SendOrderUpdate tells the owner of an order about its new state, whether or
+// not the order shows in the book
+func (h *Hub) SendOrderUpdate(order *models.Order) {
+	h.sendToUser(order.UserID, order.Symbol, "order", "order", order)
+}
+
+// SendFill tells a user about an execution of one of their orders
+func (h *Hub) SendFill(fill *models.Fill) {
+	h.sendToUser(fill.UserID, fill.Symbol, "fill", "fill", fill)
+}
+
+// SendBalanceUpdate tells a user what they now hold of an asset
+func (h *Hub) SendBalanceUpdate(balance *models.Balance) {
+	h.sendToUser(balance.UserID, "", "balance", "balance", balance)
+}
+
+// SendTriggerUpdate tells the owner of a trailing stop where its trigger has
+// moved
+func (h *Hub) SendTriggerUpdate(order *models.Order) {
+	h.sendToUser(order.UserID, order.Symbol, "trigger_update", "order", order)
+}
+
+// SendSelfTradePrevented tells a user that two of their orders would have
+// traded against each other and what was cancelled instead
+func (h *Hub) SendSelfTradePrevented(prevention *models.SelfTradePrevention) {
+	h.sendToUser(prevention.UserID, prevention.Symbol, "self_trade_prevented", "prevention", prevention)
+}
+
+// broadcastEvent sends an event to the subscribers of a market's book channel
+func (h *Hub) broadcastEvent(symbol, eventType, key string, payload interface{}) {
+	h.publish(message{channel: BookChannel(symbol)}, symbol, eventType, map[string]interface{}{key: payload})
+}
+
+// sendToUser delivers an event on the orders channel of one user only
+func (h *Hub) sendToUser(userID int64, symbol, eventType, key string, payload interface{}) {
+	h.publish(message{channel: ChannelOrders, userID: userID}, symbol, eventType, map[string]interface{}{key: payload})
+}
+
+// publish queues an event, made of its type, channel and market plus fields,
+// for delivery. The hub numbers and encodes it.
+func (h *Hub) publish(msg message, symbol, eventType string, fields map[string]interface{}) {
+	msg.event = map[string]interface{}{"type": eventType}
+	if symbol != "" {
+		msg.event["symbol"] = symbol
+	}
+	if msg.channel != "" {
+		msg.event["channel"] = msg.channel
+	}
+	for key, value := range fields {
+		msg.event[key] = value
 	}
 
-	h.broadcast <- message
+	h.broadcast <- msg
 }
@@ -0,0 +1,132 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// session is a cancel-on-disconnect session. Orders placed with its id are
+// cancelled once none of its clients has been connected for the grace period.
+type session struct {
+	userID  int64
+	grace   time.Duration
+	clients int         // connected clients using the session
+	timer   *time.Timer // pending close while no client is connected
+}
+
+// OnSessionClosed sets the function called, on its own goroutine, when a
+// cancel-on-disconnect session ends
+func (h *Hub) OnSessionClosed(fn func(userID int64, sessionID string)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onSessionClosed = fn
+}
+
+// OpenSession starts a cancel-on-disconnect session for a user and returns
+// its id. The caller connects a client with that id right away.
+func (h *Hub) OpenSession(userID int64, grace time.Duration) string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	id := hex.EncodeToString(buf)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sessions[id] = &session{userID: userID, grace: grace, clients: 1}
+
+	return id
+}
+
+// ResumeSession attaches another client to a user's session, stopping it
+// from closing if its grace period is running. It reports whether the
+// session exists.
+func (h *Hub) ResumeSession(sessionID string, userID int64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.sessions[sessionID]
+	if !ok || s.userID != userID {
+		return false
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.clients++
+
+	return true
+}
+
+// SessionActive reports whether a session of the user has a client connected
+func (h *Hub) SessionActive(sessionID string, userID int64) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	s, ok := h.sessions[sessionID]
+	return ok && s.userID == userID && s.clients > 0
+}
+
+// HasSession reports whether a session of the user exists, connected or
+// waiting out its grace period
+func (h *Hub) HasSession(sessionID string, userID int64) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	s, ok := h.sessions[sessionID]
+	return ok && s.userID == userID
+}
+
+// leaveSession detaches a removed client from its session and starts the
+// grace period once the last one is gone. The caller holds h.mu.
+func (h *Hub) leaveSession(client *Client) {
+	s, ok := h.sessions[client.sessionID]
+	if !ok {
+		return
+	}
+
+	s.clients--
+	if s.clients > 0 {
+		return
+	}
+	s.timer = time.AfterFunc(s.grace, func() { h.closeSession(client.sessionID, s) })
+}
+
+func (h *Hub) closeSession(sessionID string, s *session) {
+	h.mu.Lock()
+	if h.sessions[sessionID] != s || s.clients > 0 {
+		// Resumed in the meantime
+		h.mu.Unlock()
+		return
+	}
+	delete(h.sessions, sessionID)
+	fn := h.onSessionClosed
+	h.mu.Unlock()
+
+	log.Printf("Session %s of user %d closed", sessionID, s.userID)
+	if fn != nil {
+		fn(s.userID, sessionID)
+	}
+}
+
+// sessionInfo is the first message a session client receives, telling it
+// which id to place its orders with
+func (h *Hub) sessionInfo(client *Client) []byte {
+	s, ok := h.sessions[client.sessionID]
+	if !ok {
+		return nil
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type":                 "session",
+		"session_id":           client.sessionID,
+		"cancel_on_disconnect": true,
+		"grace_period":         s.grace.Seconds(),
+	})
+	if err != nil {
+		log.Printf("Error marshaling session: %v", err)
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,71 @@
+package websocket
+
+import "strconv"
+
+// streamHistory is how many of its latest messages each channel keeps for
+// clients resuming after a disconnect
+const streamHistory = 512
+
+// stream numbers the messages of one channel and keeps the latest of them in
+// a ring buffer, which grows as messages come until it holds streamHistory
+type stream struct {
+	next     uint64   // sequence number of the next message
+	messages [][]byte // ring buffer of the latest messages
+	start    int      // index of the oldest message in messages
+}
+
+// streamKey names the stream a channel's messages are numbered in. Each user
+// has a stream of their own on a private channel.
+func streamKey(channel string, userID int64) string {
+	if userID == 0 {
+		return channel
+	}
+	return channel + "#" + strconv.FormatInt(userID, 10)
+}
+
+// stream returns the stream of a channel, starting it on first use. Sequence
+// numbers start from the hub's start time, so numbers seen before a restart
+// are never mistaken for current ones. The caller holds h.mu.
+func (h *Hub) stream(channel string) *stream {
+	s, ok := h.streams[channel]
+	if !ok {
+		s = &stream{next: h.base + 1}
+		h.streams[channel] = s
+	}
+	return s
+}
+
+// last is the sequence number of the latest message, or the number just
+// before the first one if there has been none
+func (s *stream) last() uint64 {
+	return s.next - 1
+}
+
+// push records the message numbered next, evicting the oldest one when the
+// buffer is full
+func (s *stream) push(data []byte) {
+	if len(s.messages) < streamHistory {
+		s.messages = append(s.messages, data)
+	} else {
+		s.messages[s.start] = data
+		s.start = (s.start + 1) % len(s.messages)
+	}
+	s.next++
+}
+
+// since returns the messages numbered after seq, oldest first. It reports
+// false if some of them have been evicted or seq was never handed out.
+func (s *stream) since(seq uint64) ([][]byte, bool) {
+	count := len(s.messages)
+	oldest := s.next - uint64(count)
+	if seq+1 < oldest || seq > s.last() {
+		return nil, false
+	}
+
+	missed := int(s.last() - seq)
+	messages := make([][]byte, 0, missed)
+	for i := count - missed; i < count; i++ {
+		messages = append(messages, s.messages[(s.start+i)%len(s.messages)])
+	}
+	return messages, true
+}
@@ -0,0 +1,79 @@
+package websocket
+
+import (
+	"strconv"
+	"testing"
+)
+
+const testBase = 1000
+
+// filledStream returns a stream that has numbered count messages, each
+// carrying its own sequence number
+func filledStream(count int) *stream {
+	s := &stream{next: testBase + 1}
+	for i := 0; i < count; i++ {
+		s.push([]byte(strconv.FormatUint(s.next, 10)))
+	}
+	return s
+}
+
+func TestStreamSince(t *testing.T) {
+	tests := []struct {
+		name   string
+		count  int
+		seq    uint64
+		want   []uint64
+		wantOK bool
+	}{
+		{name: "empty, at the start", count: 0, seq: testBase, wantOK: true},
+		{name: "empty, before the start", count: 0, seq: testBase - 1},
+		{name: "empty, never handed out", count: 0, seq: testBase + 1},
+		{name: "just before the oldest", count: 3, seq: testBase, want: []uint64{1001, 1002, 1003}, wantOK: true},
+		{name: "older than the oldest", count: 3, seq: testBase - 1},
+		{name: "in the middle", count: 3, seq: 1001, want: []uint64{1002, 1003}, wantOK: true},
+		{name: "equal to the last", count: 3, seq: 1003, wantOK: true},
+		{name: "after the last", count: 3, seq: 1004},
+		{name: "full, just before the oldest", count: streamHistory + 10, seq: testBase + 10, wantOK: true},
+		{name: "full, evicted", count: streamHistory + 10, seq: testBase + 9},
+		{name: "full, equal to the last", count: streamHistory + 10, seq: testBase + streamHistory + 10, wantOK: true},
+		{name: "full, after the last", count: streamHistory + 10, seq: testBase + streamHistory + 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := filledStream(tt.count)
+			messages, ok := s.since(tt.seq)
+			if ok != tt.wantOK {
+				t.Fatalf("since(%d) ok = %v, want %v", tt.seq, ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+
+			want := tt.want
+			if want == nil {
+				for seq := tt.seq + 1; seq <= s.last(); seq++ {
+					want = append(want, seq)
+				}
+			}
+			if len(messages) != len(want) {
+				t.Fatalf("since(%d) returned %d messages, want %d", tt.seq, len(messages), len(want))
+			}
+			for i, seq := range want {
+				if got := string(messages[i]); got != strconv.FormatUint(seq, 10) {
+					t.Errorf("message %d = %s, want %d", i, got, seq)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamPushKeepsHistory(t *testing.T) {
+	s := filledStream(streamHistory*2 + 3)
+	if len(s.messages) != streamHistory {
+		t.Errorf("buffer holds %d messages, want %d", len(s.messages), streamHistory)
+	}
+	if want := uint64(testBase + streamHistory*2 + 3); s.last() != want {
+		t.Errorf("last = %d, want %d", s.last(), want)
+	}
+}
@@ -0,0 +1,278 @@
+package websocket
+
+import (
+	"crypto-orderbook/internal/utils"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Channel kinds. A market channel is a kind and a market symbol, as in
+// book.BTC-USDT; the orders channel is private and has no symbol, each
+// authenticated client only getting its own user's events on it.
+const (
+	ChannelBook   = "book"   // Orders entering, changing and leaving the book
+	ChannelTrades = "trades" // Executions
+	ChannelTicker = "ticker" // Last price and best bid and ask
+	ChannelDepth  = "depth"  // Sequenced snapshot and diffs of the aggregated book
+	ChannelOrders = "orders" // The user's order updates, fills and balances
+)
+
+// BookChannel is the channel of a market's order book events
+func BookChannel(symbol string) string {
+	return ChannelBook + "." + symbol
+}
+
+// TradesChannel is the channel of a market's executions
+func TradesChannel(symbol string) string {
+	return ChannelTrades + "." + symbol
+}
+
+// TickerChannel is the channel of a market's ticker
+func TickerChannel(symbol string) string {
+	return ChannelTicker + "." + symbol
+}
+
+// DepthChannel is the channel of a market's aggregated book
+func DepthChannel(symbol string) string {
+	return ChannelDepth + "." + symbol
+}
+
+// command is a request read from a client. Its id, if any, is echoed in the
+// reply.
+type command struct {
+	ID       json.RawMessage `json:"id,omitempty"`
+	Type     string          `json:"type"` // "auth", "subscribe", "unsubscribe", "list" or "resume"
+	Channels []string        `json:"channels"`
+
+	// Auth: the JWT to authenticate the connection with
+	Token string `json:"token"`
+
+	// Resume: the channel and the sequence number of the last message the
+	// client received on it
+	Channel  string `json:"channel"`
+	Sequence uint64 `json:"sequence"`
+}
+
+// SetSymbols sets the markets clients may subscribe to the channels of
+func (h *Hub) SetSymbols(symbols []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.symbols = make(map[string]bool)
+	for _, symbol := range symbols {
+		h.symbols[symbol] = true
+	}
+}
+
+// channelName returns a channel in its canonical form and whether it exists.
+// The caller holds h.mu.
+func (h *Hub) channelName(channel string) (string, bool) {
+	channel = strings.TrimSpace(channel)
+	if strings.ToLower(channel) == ChannelOrders {
+		return ChannelOrders, true
+	}
+
+	kind, symbol, ok := strings.Cut(channel, ".")
+	if !ok {
+		return "", false
+	}
+	kind, symbol = strings.ToLower(kind), strings.ToUpper(symbol)
+
+	switch kind {
+	case ChannelBook, ChannelTrades, ChannelTicker, ChannelDepth:
+		return kind + "." + symbol, h.symbols[symbol]
+	}
+	return "", false
+}
+
+// handleCommand runs a command read from a client and acknowledges it, or
+// replies with an error
+func (h *Hub) handleCommand(client *Client, data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if client.closed {
+		return
+	}
+
+	var cmd command
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		h.reply(client, cmd.ID, "error", map[string]interface{}{"error": "Invalid command"})
+		return
+	}
+
+	switch cmd.Type {
+	case "subscribe", "unsubscribe":
+		if len(cmd.Channels) == 0 {
+			h.reply(client, cmd.ID, "error", map[string]interface{}{"error": "No channels given"})
+			return
+		}
+
+		channels := make([]string, len(cmd.Channels))
+		for i, channel := range cmd.Channels {
+			name, ok := h.channelName(channel)
+			if !ok {
+				h.reply(client, cmd.ID, "error", map[string]interface{}{"error": "Unknown channel: " + channel})
+				return
+			}
+			if name == ChannelOrders && client.userID == 0 {
+				h.reply(client, cmd.ID, "error", map[string]interface{}{"error": "Authentication required for channel: " + name})
+				return
+			}
+			channels[i] = name
+		}
+
+		if cmd.Type == "unsubscribe" {
+			for _, channel := range channels {
+				delete(client.channels, channel)
+			}
+			h.reply(client, cmd.ID, "unsubscribed", map[string]interface{}{"channels": channels})
+			return
+		}
+
+		// New subscribers start from the channel's initial message; depth
+		// subscribers get a fresh snapshot every time, which is how they
+		// resync after a gap
+		var started []string
+		for _, channel := range channels {
+			if !client.channels[channel] || strings.HasPrefix(channel, ChannelDepth+".") {
+				client.channels[channel] = true
+				started = append(started, channel)
+			}
+		}
+		sequences := make(map[string]uint64, len(channels))
+		for _, channel := range channels {
+			sequences[channel] = h.clientStream(client, channel).last()
+		}
+		h.reply(client, cmd.ID, "subscribed", map[string]interface{}{
+			"channels":  channels,
+			"sequences": sequences,
+		})
+
+		for _, channel := range started {
+			if data := h.initialMessage(channel); data != nil && !client.closed {
+				h.deliver(client, data)
+			}
+		}
+
+	case "list":
+		channels := make([]string, 0, len(client.channels))
+		for channel := range client.channels {
+			channels = append(channels, channel)
+		}
+		sort.Strings(channels)
+		h.reply(client, cmd.ID, "subscriptions", map[string]interface{}{"channels": channels})
+
+	case "resume":
+		h.resume(client, &cmd)
+
+	case "auth":
+		h.authenticate(client, &cmd)
+
+	default:
+		h.reply(client, cmd.ID, "error", map[string]interface{}{"error": "Command type must be 'auth', 'subscribe', 'unsubscribe', 'list' or 'resume'"})
+	}
+}
+
+// authenticate attaches the user of a token to a client and subscribes it to
+// the user's orders channel, as connecting with the token would have. A
+// client stays with the user it first authenticated as, though it may send
+// a fresh token for that user. The caller holds h.mu.
+func (h *Hub) authenticate(client *Client, cmd *command) {
+	claims, err := utils.ValidateToken(cmd.Token, h.secret)
+	if err != nil {
+		h.reply(client, cmd.ID, "error", map[string]interface{}{"error": "Invalid or expired token"})
+		return
+	}
+	if client.userID != 0 && client.userID != claims.UserID {
+		h.reply(client, cmd.ID, "error", map[string]interface{}{"error": "Already authenticated as another user"})
+		return
+	}
+
+	client.userID = claims.UserID
+	client.channels[ChannelOrders] = true
+	h.reply(client, cmd.ID, "authenticated", map[string]interface{}{
+		"user_id":  claims.UserID,
+		"channel":  ChannelOrders,
+		"sequence": h.clientStream(client, ChannelOrders).last(),
+	})
+}
+
+// resume subscribes a client to a channel again and replays the messages it
+// missed since the sequence number it last received. If some of them are no
+// longer kept, or would not fit in the client's queue, it is told to start
+// over from a snapshot instead and is not subscribed. The caller holds h.mu.
+func (h *Hub) resume(client *Client, cmd *command) {
+	channel, ok := h.channelName(cmd.Channel)
+	if !ok {
+		h.reply(client, cmd.ID, "error", map[string]interface{}{"error": "Unknown channel: " + cmd.Channel})
+		return
+	}
+	if channel == ChannelOrders && client.userID == 0 {
+		h.reply(client, cmd.ID, "error", map[string]interface{}{"error": "Authentication required for channel: " + channel})
+		return
+	}
+
+	missed, ok := h.clientStream(client, channel).since(cmd.Sequence)
+	if !ok || len(missed) >= cap(client.send)-len(client.send) {
+		h.reply(client, cmd.ID, "snapshot_required", map[string]interface{}{
+			"channel":  channel,
+			"sequence": cmd.Sequence,
+		})
+		return
+	}
+
+	client.channels[channel] = true
+	h.reply(client, cmd.ID, "resumed", map[string]interface{}{
+		"channel":  channel,
+		"sequence": cmd.Sequence,
+		"replayed": len(missed),
+	})
+	for _, data := range missed {
+		if client.closed {
+			return
+		}
+		h.deliver(client, data)
+	}
+}
+
+// clientStream is the stream a client gets a channel's messages from, which
+// for the orders channel is that of the client's user. The caller holds h.mu.
+func (h *Hub) clientStream(client *Client, channel string) *stream {
+	if channel == ChannelOrders {
+		return h.stream(streamKey(channel, client.userID))
+	}
+	return h.stream(channel)
+}
+
+// initialMessage is what a new subscriber to a channel starts from: a
+// snapshot of the aggregated book for depth channels, otherwise the latest
+// retained message, such as the current ticker. The caller holds h.mu.
+func (h *Hub) initialMessage(channel string) []byte {
+	kind, symbol, _ := strings.Cut(channel, ".")
+	if kind == ChannelDepth {
+		return h.depthSnapshot(symbol)
+	}
+	return h.retained[channel]
+}
+
+// reply sends a client the answer to one of its commands. The caller holds
+// h.mu.
+func (h *Hub) reply(client *Client, id json.RawMessage, replyType string, fields map[string]interface{}) {
+	event := map[string]interface{}{"type": replyType}
+	if len(id) > 0 {
+		event["id"] = id
+	}
+	for key, value := range fields {
+		event[key] = value
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling %s: %v", replyType, err)
+		return
+	}
+	h.deliver(client, data)
+}
@@ -0,0 +1,170 @@
+import { useCallback, useEffect, useState } from 'react';
+import { authService } from '../../services/authService';
+import { balanceService } from '../../services/balanceService';
+import { fundingService } from '../../services/fundingService';
+import type { Balance } from '../../types/balance';
+import type { FeeSchedule } from '../../types/fee';
+import { DEFAULT_SYMBOL } from '../../types/order';
+
+interface BalancesProps {
+  refreshKey: number; // değişince bakiyeler yeniden çekiliyor, örn. emir verildikten sonra
+}
+
+// Testnet yatırmaları onay bekleyebiliyor, o yüzden elle yenileme de var
+export const Balances = ({ refreshKey }: BalancesProps) => {
+  const [balances, setBalances] = useState<Balance[]>([]);
+  const [fees, setFees] = useState<FeeSchedule | null>(null);
+  const [asset, setAsset] = useState('USDT');
+  const [amount, setAmount] = useState('');
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState('');
+
+  const fetchBalances = useCallback(async () => {
+    try {
+      setBalances(await balanceService.getBalances());
+    } catch (error) {
+      console.error('Failed to fetch balances:', error);
+    }
+  }, []);
+
+  // Seviye 30 günlük hacimden hesaplanıyor, işlem yaptıkça değişebiliyor
+  const fetchFees = useCallback(async () => {
+    try {
+      setFees(await authService.getFees());
+    } catch (error) {
+      console.error('Failed to fetch fees:', error);
+    }
+  }, []);
+
+  useEffect(() => {
+    fetchBalances();
+    fetchFees();
+  }, [fetchBalances, fetchFees, refreshKey]);
+
+  const marketFees = fees?.markets.find((m) => m.symbol === DEFAULT_SYMBOL);
+
+  const handleDeposit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    setLoading(true);
+
+    try {
+      await fundingService.createDeposit({ asset: asset.trim().toUpperCase(), amount });
+      setAmount('');
+      await fetchBalances();
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Failed to deposit');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="bg-gray-800 p-6 rounded-lg shadow-lg mt-6">
+      <div className="flex items-center justify-between mb-4">
+        <h3 className="text-xl font-bold text-white">Balances</h3>
+        <button
+          type="button"
+          onClick={() => {
+            fetchBalances();
+            fetchFees();
+          }}
+          className="text-sm text-gray-300 hover:text-white"
+        >
+          Refresh
+        </button>
+      </div>
+
+      <table className="w-full mb-6">
+        <thead>
+          <tr className="text-gray-400 border-b border-gray-700">
+            <th className="text-left py-2">Asset</th>
+            <th className="text-left py-2">Available</th>
+            <th className="text-left py-2">Locked</th>
+          </tr>
+        </thead>
+        <tbody>
+          {balances.length === 0 ? (
+            <tr>
+              <td colSpan={3} className="text-center text-gray-500 py-4">
+                No balances yet
+              </td>
+            </tr>
+          ) : (
+            balances.map((balance) => (
+              <tr key={balance.asset} className="border-b border-gray-700">
+                <td className="py-2 text-white">{balance.asset}</td>
+                <td className="py-2 text-white">{Number(balance.available).toFixed(8)}</td>
+                <td className="py-2 text-gray-400">{Number(balance.locked).toFixed(8)}</td>
+              </tr>
+            ))
+          )}
+        </tbody>
+      </table>
+
+      {fees && (
+        <div className="text-sm text-gray-300 mb-6 space-y-1">
+          <div className="flex justify-between">
+            <span>Fee tier</span>
+            <span className="text-white">{fees.tier.tier}</span>
+          </div>
+          <div className="flex justify-between">
+            <span>30d volume</span>
+            <span className="text-white">
+              {Number(fees.volume_30d).toFixed(2)} {fees.volume_asset}
+            </span>
+          </div>
+          {fees.volume_to_next_tier && (
+            <div className="flex justify-between">
+              <span>To next tier</span>
+              <span className="text-white">
+                {Number(fees.volume_to_next_tier).toFixed(2)} {fees.volume_asset}
+              </span>
+            </div>
+          )}
+          {marketFees && (
+            <div className="flex justify-between">
+              <span>Maker / taker</span>
+              <span className="text-white">
+                {(Number(marketFees.maker_rate) * 100).toFixed(3)}% / {(Number(marketFees.taker_rate) * 100).toFixed(3)}%
+              </span>
+            </div>
+          )}
+        </div>
+      )}
+
+      {error && (
+        <div className="bg-red-500 text-white p-3 rounded mb-4">
+          {error}
+        </div>
+      )}
+
+      <form onSubmit={handleDeposit} className="flex gap-2">
+        <input
+          type="text"
+          value={asset}
+          onChange={(e) => setAsset(e.target.value)}
+          className="w-24 px-3 py-2 bg-gray-700 text-white rounded focus:outline-none focus:ring-2 focus:ring-blue-500"
+          placeholder="Asset"
+          required
+        />
+        <input
+          type="number"
+          step="0.00000001"
+          value={amount}
+          onChange={(e) => setAmount(e.target.value)}
+          className="flex-1 px-3 py-2 bg-gray-700 text-white rounded focus:outline-none focus:ring-2 focus:ring-blue-500"
+          placeholder="0.00"
+          required
+        />
+        <button
+          type="submit"
+          disabled={loading}
+          className="bg-blue-600 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded disabled:opacity-50"
+        >
+          {loading ? 'Depositing...' : 'Deposit'}
+        </button>
+      </form>
+    </div>
+  );
+};
\ No newline at end of file
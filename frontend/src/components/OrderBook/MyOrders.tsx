@@ -0,0 +1,114 @@
+import { useCallback, useEffect, useState } from 'react';
+import { orderService } from '../../services/orderService';
+import type { Order } from '../../types/order';
+
+interface MyOrdersProps {
+  refreshKey: number; // refetch when it changes, e.g. after an order is placed
+  onOrdersChanged: () => void;
+}
+
+// Orders that can still be cancelled, including stops waiting for their trigger
+const OPEN_STATUSES: Order['status'][] = ['pending_activation', 'pending_trigger', 'active', 'partially_filled'];
+
+export const MyOrders = ({ refreshKey, onOrdersChanged }: MyOrdersProps) => {
+  const [orders, setOrders] = useState<Order[]>([]);
+  const [cancelling, setCancelling] = useState<number | null>(null);
+  const [error, setError] = useState('');
+
+  const fetchOrders = useCallback(async () => {
+    try {
+      const all = await orderService.getMyOrders();
+      setOrders(all.filter((order) => OPEN_STATUSES.includes(order.status)));
+    } catch (error) {
+      console.error('Failed to fetch orders:', error);
+    }
+  }, []);
+
+  useEffect(() => {
+    fetchOrders();
+  }, [fetchOrders, refreshKey]);
+
+  const handleCancel = async (id: number) => {
+    setError('');
+    setCancelling(id);
+
+    try {
+      await orderService.cancelOrder(id);
+      onOrdersChanged();
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Failed to cancel order');
+      await fetchOrders();
+    } finally {
+      setCancelling(null);
+    }
+  };
+
+  return (
+    <div className="bg-gray-800 p-6 rounded-lg shadow-lg">
+      <div className="flex items-center justify-between mb-4">
+        <h3 className="text-xl font-bold text-white">My Open Orders</h3>
+        <button
+          type="button"
+          onClick={fetchOrders}
+          className="text-sm text-gray-300 hover:text-white"
+        >
+          Refresh
+        </button>
+      </div>
+
+      {error && (
+        <div className="bg-red-500 text-white p-3 rounded mb-4">
+          {error}
+        </div>
+      )}
+
+      <div className="overflow-x-auto">
+        <table className="w-full">
+          <thead>
+            <tr className="text-gray-400 border-b border-gray-700">
+              <th className="text-left py-2">Symbol</th>
+              <th className="text-left py-2">Side</th>
+              <th className="text-left py-2">Price</th>
+              <th className="text-left py-2">Remaining</th>
+              <th className="text-left py-2">Status</th>
+              <th className="py-2"></th>
+            </tr>
+          </thead>
+          <tbody>
+            {orders.length === 0 ? (
+              <tr>
+                <td colSpan={6} className="text-center text-gray-500 py-4">
+                  No open orders
+                </td>
+              </tr>
+            ) : (
+              orders.map((order) => (
+                <tr key={order.id} className="border-b border-gray-700">
+                  <td className="py-2 text-white">{order.symbol}</td>
+                  <td className={`py-2 ${order.order_type === 'buy' ? 'text-green-400' : 'text-red-400'}`}>
+                    {order.order_type === 'buy' ? 'Buy' : 'Sell'}
+                  </td>
+                  <td className="py-2 text-white">
+                    {order.price ? `$${Number(order.price).toFixed(2)}` : order.order_kind}
+                  </td>
+                  <td className="py-2 text-white">{Number(order.remaining_amount).toFixed(8)}</td>
+                  <td className="py-2 text-gray-400">{order.status}</td>
+                  <td className="py-2 text-right">
+                    <button
+                      type="button"
+                      onClick={() => handleCancel(order.id)}
+                      disabled={cancelling === order.id}
+                      className="text-sm text-red-400 hover:text-red-300 disabled:opacity-50"
+                    >
+                      {cancelling === order.id ? 'Cancelling...' : 'Cancel'}
+                    </button>
+                  </td>
+                </tr>
+              ))
+            )}
+          </tbody>
+        </table>
+      </div>
+    </div>
+  );
+};
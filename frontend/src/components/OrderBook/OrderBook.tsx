@@ -1,51 +1,20 @@
-import { useState, useEffect, useCallback } from 'react';
-import { orderService } from '../../services/orderService';
+import { useState } from 'react';
 import { BuyOrders } from './BuyOrders';
 import { SellOrders } from './SellOrders';
 import { OrderForm } from './OrderForm';
-import { useWebSocket } from '../../hooks/useWebSocket';
-import type { Order } from '../../types/order';
+import { Balances } from './Balances';
+import { MyOrders } from './MyOrders';
+import { useDepth } from '../../hooks/useDepth';
+import { DEFAULT_SYMBOL } from '../../types/order';
 
 export const OrderBook = () => {
-  const [buyOrders, setBuyOrders] = useState<Order[]>([]);
-  const [sellOrders, setSellOrders] = useState<Order[]>([]);
-  const [loading, setLoading] = useState(true);
+  // Defter depth kanalından geliyor: önce snapshot, sonra sıra numaralı farklar.
+  // REST'ten çekip olay uygulamadaki yarış durumu yok, boşluk görülünce yeni snapshot isteniyor
+  const depth = useDepth(DEFAULT_SYMBOL);
+  // Placing or cancelling orders changes balances and open orders; bumping this refetches both
+  const [version, setVersion] = useState(0);
 
-  const fetchOrders = useCallback(async () => {
-    try {
-      const data = await orderService.getOrderBook();
-      setBuyOrders(data.buy_orders || []);
-      setSellOrders(data.sell_orders || []);
-    } catch (error) {
-      console.error('Failed to fetch orders:', error);
-    } finally {
-      setLoading(false);
-    }
-  }, []);
-
-  useEffect(() => {
-    fetchOrders();
-  }, [fetchOrders]);
-
-  const handleNewOrder = useCallback((order: Order) => {
-    if (order.order_type === 'buy') {
-      setBuyOrders((prev) => {
-        // Eğer order zaten varsa ekleme
-        if (prev.some(o => o.id === order.id)) return prev;
-        return [order, ...prev];
-      });
-    } else {
-      setSellOrders((prev) => {
-        // Eğer order zaten varsa ekleme
-        if (prev.some(o => o.id === order.id)) return prev;
-        return [order, ...prev];
-      });
-    }
-  }, []);
-
-  useWebSocket(handleNewOrder);
-
-  if (loading) {
+  if (depth.sequence === 0) {
     return (
       <div className="min-h-screen bg-gray-900 flex items-center justify-center">
         <div className="text-white text-2xl">Loading...</div>
@@ -63,13 +32,20 @@ export const OrderBook = () => {
         <div className="grid grid-cols-1 lg:grid-cols-3 gap-6 mb-8">
           <div className="lg:col-span-2">
             <div className="grid grid-cols-1 md:grid-cols-2 gap-6">
-              <BuyOrders orders={buyOrders} />
-              <SellOrders orders={sellOrders} />
+              <BuyOrders levels={depth.bids} />
+              <SellOrders levels={depth.asks} />
+            </div>
+            <div className="mt-6">
+              <MyOrders
+                refreshKey={version}
+                onOrdersChanged={() => setVersion((v) => v + 1)}
+              />
             </div>
           </div>
           
           <div>
-            <OrderForm onOrderCreated={() => {}} />
+            <OrderForm onOrdersChanged={() => setVersion((v) => v + 1)} />
+            <Balances refreshKey={version} />
           </div>
         </div>
       </div>
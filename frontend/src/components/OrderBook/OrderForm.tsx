@@ -1,32 +1,37 @@
 import { useState } from 'react';
 import { orderService } from '../../services/orderService';
+import { DEFAULT_SYMBOL } from '../../types/order';
 
 interface OrderFormProps {
-  onOrderCreated: () => void;
+  onOrdersChanged: () => void; // emir verilince ya da toplu iptalde bakiyeler değişiyor
 }
 
-export const OrderForm = ({ onOrderCreated }: OrderFormProps) => {
+export const OrderForm = ({ onOrdersChanged }: OrderFormProps) => {
   const [orderType, setOrderType] = useState<'buy' | 'sell'>('buy');
   const [price, setPrice] = useState('');
   const [amount, setAmount] = useState('');
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState('');
+  const [cancelling, setCancelling] = useState(false);
+  const [cancelled, setCancelled] = useState<number | null>(null);
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
     setError('');
+    setCancelled(null);
     setLoading(true);
 
     try {
       await orderService.createOrder({
+        symbol: DEFAULT_SYMBOL,
         order_type: orderType,
-        price: parseFloat(price),
-        amount: parseFloat(amount),
+        price,
+        amount,
       });
       
       setPrice('');
       setAmount('');
-      onOrderCreated();
+      onOrdersChanged();
     } catch (err: any) {
       setError(err.response?.data?.error || 'Failed to create order');
     } finally {
@@ -34,6 +39,23 @@ export const OrderForm = ({ onOrderCreated }: OrderFormProps) => {
     }
   };
 
+  // Sadece bu semboldeki açık emirler; bekleyen bracket bacakları sunucu tarafında ayarlanıyor
+  const handleCancelAll = async () => {
+    setError('');
+    setCancelled(null);
+    setCancelling(true);
+
+    try {
+      const result = await orderService.cancelAllOrders({ symbol: DEFAULT_SYMBOL });
+      setCancelled(result.cancelled.length);
+      onOrdersChanged();
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Failed to cancel orders');
+    } finally {
+      setCancelling(false);
+    }
+  };
+
   return (
     <div className="bg-gray-800 p-6 rounded-lg shadow-lg">
       <h3 className="text-xl font-bold text-white mb-4">Create Order</h3>
@@ -44,6 +66,12 @@ export const OrderForm = ({ onOrderCreated }: OrderFormProps) => {
         </div>
       )}
 
+      {cancelled !== null && (
+        <div className="bg-gray-700 text-gray-200 p-3 rounded mb-4">
+          Cancelled {cancelled} {cancelled === 1 ? 'order' : 'orders'}
+        </div>
+      )}
+
       <form onSubmit={handleSubmit}>
         <div className="mb-4">
           <label className="block text-gray-300 mb-2">Order Type</label>
@@ -111,6 +139,15 @@ export const OrderForm = ({ onOrderCreated }: OrderFormProps) => {
           {loading ? 'Creating...' : `${orderType === 'buy' ? 'Buy' : 'Sell'}`}
         </button>
       </form>
+
+      <button
+        type="button"
+        onClick={handleCancelAll}
+        disabled={cancelling}
+        className="w-full mt-3 py-2 px-4 rounded bg-gray-700 hover:bg-gray-600 text-gray-200 disabled:opacity-50"
+      >
+        {cancelling ? 'Cancelling...' : 'Cancel all my orders'}
+      </button>
     </div>
   );
 };
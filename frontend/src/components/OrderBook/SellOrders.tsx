@@ -1,9 +1,9 @@
-import type { Order } from '../../types/order';
+import type { PriceLevel } from '../../types/depth';
 interface SellOrdersProps {
-  orders: Order[];
+  levels: PriceLevel[];
 }
 
-export const SellOrders = ({ orders }: SellOrdersProps) => {
+export const SellOrders = ({ levels }: SellOrdersProps) => {
   return (
     <div className="bg-gray-800 p-6 rounded-lg shadow-lg">
       <h3 className="text-xl font-bold text-red-500 mb-4">Sell Orders</h3>
@@ -15,23 +15,21 @@ export const SellOrders = ({ orders }: SellOrdersProps) => {
               <th className="text-left py-2">Price</th>
               <th className="text-left py-2">Amount</th>
               <th className="text-left py-2">Total</th>
-              <th className="text-left py-2">User</th>
             </tr>
           </thead>
           <tbody>
-            {orders.length === 0 ? (
+            {levels.length === 0 ? (
               <tr>
-                <td colSpan={4} className="text-center text-gray-500 py-4">
+                <td colSpan={3} className="text-center text-gray-500 py-4">
                   No sell orders
                 </td>
               </tr>
             ) : (
-              orders.map((order) => (
-                <tr key={order.id} className="border-b border-gray-700 hover:bg-gray-700">
-                  <td className="py-2 text-red-400">${order.price.toFixed(2)}</td>
-                  <td className="py-2 text-white">{order.amount.toFixed(8)}</td>
-                  <td className="py-2 text-white">${(order.price * order.amount).toFixed(2)}</td>
-                  <td className="py-2 text-gray-400">{order.username}</td>
+              levels.map((level) => (
+                <tr key={level.price} className="border-b border-gray-700 hover:bg-gray-700">
+                  <td className="py-2 text-red-400">${Number(level.price).toFixed(2)}</td>
+                  <td className="py-2 text-white">{Number(level.amount).toFixed(8)}</td>
+                  <td className="py-2 text-white">${(Number(level.price) * Number(level.amount)).toFixed(2)}</td>
                 </tr>
               ))
             )}
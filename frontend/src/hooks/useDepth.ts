@@ -0,0 +1,87 @@
+import { useEffect, useRef, useState } from 'react';
+import type { Depth, DepthMessage, PriceLevel } from '../types/depth';
+
+const WS_URL = 'ws://localhost:8080/ws';
+
+// Güncellemeleri seviyelere uygula, miktarı 0 olanları sil
+const applyLevels = (levels: PriceLevel[], changes: PriceLevel[], descending: boolean) => {
+  const byPrice = new Map(levels.map((level) => [level.price, level]));
+  changes.forEach((change) => {
+    if (Number(change.amount) === 0) {
+      byPrice.delete(change.price);
+    } else {
+      byPrice.set(change.price, change);
+    }
+  });
+  return [...byPrice.values()].sort((a, b) =>
+    descending ? Number(b.price) - Number(a.price) : Number(a.price) - Number(b.price)
+  );
+};
+
+// Keeps the aggregated book of a market: the visible amount at each price,
+// summed by the server. Hidden iceberg reserves are not included.
+export const useDepth = (symbol: string) => {
+  const [depth, setDepth] = useState<Depth>({ sequence: 0, bids: [], asks: [] });
+  const depthRef = useRef<Depth | null>(null);
+  const wsRef = useRef<WebSocket | null>(null);
+  const reconnectTimeoutRef = useRef<number | undefined>(undefined);
+
+  useEffect(() => {
+    const channel = `depth.${symbol}`;
+    const subscribe = (ws: WebSocket) => {
+      depthRef.current = null;
+      ws.send(JSON.stringify({ type: 'subscribe', channels: [channel] }));
+    };
+
+    const connect = () => {
+      const ws = new WebSocket(WS_URL);
+
+      ws.onopen = () => subscribe(ws);
+
+      ws.onmessage = (event) => {
+        const data = JSON.parse(event.data) as DepthMessage;
+        if (data.channel !== channel) return;
+
+        if (data.type === 'depth_snapshot') {
+          depthRef.current = { sequence: data.sequence, bids: data.bids, asks: data.asks };
+        } else if (data.type === 'depth_update') {
+          const current = depthRef.current;
+          if (!current || data.sequence <= current.sequence) return;
+          // Sıra numarasında boşluk varsa mesaj kaçmış, yeni snapshot iste
+          if (data.sequence !== current.sequence + 1) {
+            subscribe(ws);
+            return;
+          }
+          depthRef.current = {
+            sequence: data.sequence,
+            bids: applyLevels(current.bids, data.bids, true),
+            asks: applyLevels(current.asks, data.asks, false),
+          };
+        } else {
+          return;
+        }
+        setDepth(depthRef.current);
+      };
+
+      ws.onclose = () => {
+        reconnectTimeoutRef.current = setTimeout(connect, 3000) as unknown as number;
+      };
+
+      wsRef.current = ws;
+    };
+
+    connect();
+
+    return () => {
+      if (reconnectTimeoutRef.current) {
+        clearTimeout(reconnectTimeoutRef.current);
+      }
+      if (wsRef.current) {
+        wsRef.current.onclose = null;
+        wsRef.current.close();
+      }
+    };
+  }, [symbol]);
+
+  return depth;
+};
@@ -1,71 +0,0 @@
-import { useEffect, useRef, useState } from 'react';
-import type { Order } from '../types/order';
-
-const WS_URL = 'ws://localhost:8080/ws';
-
-export const useWebSocket = (onOrderReceived: (order: Order) => void) => {
-  const [isConnected, setIsConnected] = useState(false);
-  const wsRef = useRef<WebSocket | null>(null);
-  const reconnectTimeoutRef = useRef<number | undefined>(undefined);
-  const callbackRef = useRef(onOrderReceived);
-
-  // Callback'i her zaman güncel tut
-  useEffect(() => {
-    callbackRef.current = onOrderReceived;
-  }, [onOrderReceived]);
-
-  const connect = () => {
-    try {
-      const ws = new WebSocket(WS_URL);
-
-      ws.onopen = () => {
-        console.log('WebSocket connected');
-        setIsConnected(true);
-      };
-
-      ws.onmessage = (event) => {
-        try {
-          const data = JSON.parse(event.data);
-          if (data.type === 'new_order' && data.order) {
-            callbackRef.current(data.order);
-          }
-        } catch (error) {
-          console.error('Error parsing message:', error);
-        }
-      };
-
-      ws.onerror = (error) => {
-        console.error('WebSocket error:', error);
-      };
-
-      ws.onclose = () => {
-        console.log('WebSocket disconnected');
-        setIsConnected(false);
-        
-        reconnectTimeoutRef.current = setTimeout(() => {
-          console.log('Reconnecting...');
-          connect();
-        }, 3000) as unknown as number;
-      };
-
-      wsRef.current = ws;
-    } catch (error) {
-      console.error('Error creating WebSocket:', error);
-    }
-  };
-
-  useEffect(() => {
-    connect();
-
-    return () => {
-      if (reconnectTimeoutRef.current) {
-        clearTimeout(reconnectTimeoutRef.current);
-      }
-      if (wsRef.current) {
-        wsRef.current.close();
-      }
-    };
-  }, []);
-
-  return { isConnected };
-};
\ No newline at end of file
@@ -1,5 +1,6 @@
 import api from './api';
-import type { AuthResponse, LoginRequest, RegisterRequest } from '../types/user';
+import type { FeeSchedule } from '../types/fee';
+import type { AuthResponse, LoginRequest, RegisterRequest, StpMode, User } from '../types/user';
 export const authService = {
   register: async (data: RegisterRequest): Promise<AuthResponse> => {
     const response = await api.post<AuthResponse>('/auth/register', data);
@@ -29,6 +30,22 @@ export const authService = {
     return userStr ? JSON.parse(userStr) : null;
   },
 
+  getAccount: async (): Promise<User> => {
+    const response = await api.get<User>('/account');
+    return response.data;
+  },
+
+  updateAccount: async (data: { default_stp_mode: StpMode }): Promise<User> => {
+    const response = await api.patch<User>('/account', data);
+    localStorage.setItem('user', JSON.stringify(response.data));
+    return response.data;
+  },
+
+  getFees: async (): Promise<FeeSchedule> => {
+    const response = await api.get<FeeSchedule>('/account/fees');
+    return response.data;
+  },
+
   isAuthenticated: (): boolean => {
     return !!localStorage.getItem('token');
   },
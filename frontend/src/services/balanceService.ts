@@ -0,0 +1,9 @@
+import api from './api';
+
+import type { Balance } from '../types/balance';
+export const balanceService = {
+  getBalances: async (): Promise<Balance[]> => {
+    const response = await api.get<Balance[]>('/balances');
+    return response.data;
+  },
+};
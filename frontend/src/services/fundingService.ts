@@ -0,0 +1,9 @@
+import api from './api';
+
+import type { CreateDepositRequest, Deposit } from '../types/funding';
+export const fundingService = {
+  createDeposit: async (data: CreateDepositRequest): Promise<Deposit> => {
+    const response = await api.post<Deposit>('/deposits', data);
+    return response.data;
+  },
+};
@@ -1,19 +1,24 @@
 import api from './api';
 
-import type { CreateOrderRequest, OrderBook } from '../types/order';
+import type { CreateOrderRequest, Order } from '../types/order';
 export const orderService = {
-  getOrderBook: async (): Promise<OrderBook> => {
-    const response = await api.get<OrderBook>('/orders');
+  createOrder: async (data: CreateOrderRequest) => {
+    const response = await api.post('/orders', data);
     return response.data;
   },
 
-  createOrder: async (data: CreateOrderRequest) => {
-    const response = await api.post('/orders', data);
+  getMyOrders: async (): Promise<Order[]> => {
+    const response = await api.get<Order[]>('/orders/my');
+    return response.data;
+  },
+
+  cancelOrder: async (id: number) => {
+    const response = await api.delete(`/orders/${id}`);
     return response.data;
   },
 
-  getMyOrders: async () => {
-    const response = await api.get('/orders/my');
+  cancelAllOrders: async (filters: { side?: 'buy' | 'sell'; symbol?: string } = {}) => {
+    const response = await api.delete<{ cancelled: number[] }>('/orders', { params: filters });
     return response.data;
   },
 };
@@ -0,0 +1,6 @@
+export interface Balance {
+  asset: string;
+  available: string;
+  locked: string; // açık emirler için ayrılan miktar
+  updated_at: string;
+}
@@ -0,0 +1,19 @@
+export interface PriceLevel {
+  price: string;
+  amount: string; // o fiyattaki toplam görünen miktar, "0" ise seviye silindi
+}
+
+export interface DepthMessage {
+  type: 'depth_snapshot' | 'depth_update';
+  channel: string;
+  symbol: string;
+  sequence: number;
+  bids: PriceLevel[];
+  asks: PriceLevel[];
+}
+
+export interface Depth {
+  sequence: number;
+  bids: PriceLevel[]; // yüksek fiyat önce
+  asks: PriceLevel[]; // düşük fiyat önce
+}
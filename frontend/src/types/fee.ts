@@ -0,0 +1,22 @@
+export interface FeeTier {
+  tier: number;
+  min_volume: string;
+  maker_discount: string; // "0.25" = %25 indirim
+  taker_discount: string;
+}
+
+export interface MarketFees {
+  symbol: string;
+  maker_rate: string;
+  taker_rate: string;
+}
+
+export interface FeeSchedule {
+  tier: FeeTier;
+  volume_30d: string;
+  volume_asset: string;
+  updated_at?: string;
+  next_tier?: FeeTier; // en üst seviyede yok
+  volume_to_next_tier?: string;
+  markets: MarketFees[];
+}
@@ -0,0 +1,18 @@
+export type DepositStatus = 'pending' | 'completed';
+
+export interface Deposit {
+  id: number;
+  user_id: number;
+  asset: string;
+  amount: string;
+  status: DepositStatus;
+  confirmations: number;
+  required_confirmations: number; // 0 ise anında yatırılıyor
+  created_at: string;
+  completed_at?: string;
+}
+
+export interface CreateDepositRequest {
+  asset: string;
+  amount: string;
+}
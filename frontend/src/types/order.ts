@@ -1,21 +1,56 @@
+import type { StpMode } from './user';
+
 export interface Order {
   id: number;
   user_id: number;
   username: string;
+  symbol: string;
   order_type: 'buy' | 'sell';
-  price: number;
-  amount: number;
-  status: string;
+  order_kind: 'limit' | 'market' | 'stop' | 'stop_limit' | 'trailing_stop';
+  // Decimal alanlar hassasiyet kaybı olmasın diye string olarak geliyor
+  price?: string; // market ve stop emirlerinde yok
+  trigger_price?: string; // sadece stop emirlerinde, trailing stop'ta güncel seviye
+  trail_offset?: string;
+  trail_type?: 'absolute' | 'percentage';
+  amount: string;
+  filled_amount: string;
+  remaining_amount: string;
+  avg_fill_price: string;
+  status: 'pending_activation' | 'pending_trigger' | 'active' | 'partially_filled' | 'filled' | 'cancelled' | 'expired';
+  time_in_force: 'GTC' | 'IOC' | 'FOK' | 'GTD';
+  expires_at?: string;
+  display_amount?: string; // iceberg emirlerinde, sadece emrin sahibine
+  visible_amount?: string;
+  post_only: boolean;
+  reduce_only: boolean;
+  group_id?: number; // OCO veya bracket grubu
+  group_role?: 'entry' | 'take_profit' | 'stop_loss';
+  session_id?: string; // cancel-on-disconnect oturumu
+  stp_mode: StpMode;
+  locked_amount?: string; // emir için ayrılan bakiye
   created_at: string;
 }
 
 export interface CreateOrderRequest {
+  symbol: string;
   order_type: 'buy' | 'sell';
-  price: number;
-  amount: number;
+  order_kind?: 'limit' | 'market' | 'stop' | 'stop_limit' | 'trailing_stop';
+  price?: string;
+  trigger_price?: string;
+  trail_offset?: string;
+  trail_type?: 'absolute' | 'percentage';
+  amount: string;
+  time_in_force?: 'GTC' | 'IOC' | 'FOK' | 'GTD';
+  expires_at?: string;
+  display_amount?: string;
+  post_only?: boolean;
+  post_only_reprice?: boolean;
+  reduce_only?: boolean;
+  session_id?: string;
+  stp_mode?: StpMode; // verilmezse hesabın default_stp_mode'u
+  worst_price?: string;
+  max_slippage?: string;
 }
 
-export interface OrderBook {
-  buy_orders: Order[];
-  sell_orders: Order[];
-}
\ No newline at end of file
+// Şimdilik arayüz tek bir market gösteriyor
+export const DEFAULT_SYMBOL = 'BTC-USDT';
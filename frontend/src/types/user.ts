@@ -2,8 +2,11 @@ export interface User {
   id: number;
   email: string;
   username: string;
+  default_stp_mode?: StpMode;
 }
 
+export type StpMode = 'none' | 'cancel_newest' | 'cancel_oldest' | 'cancel_both' | 'decrement_cancel';
+
 export interface RegisterRequest {
   email: string;
   username: string;